@@ -0,0 +1,152 @@
+package brew
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// escapeLen returns the length of the escape sequence at the start of s, or 0
+// when s does not start with ESC. Unterminated sequences extend to the end of
+// s.
+func escapeLen(s string) int {
+	if len(s) == 0 || s[0] != 0x1b {
+		return 0
+	}
+	if len(s) == 1 {
+		return 1
+	}
+
+	switch s[1] {
+	case '[':
+		// CSI: parameters and intermediates up to a final byte
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7e {
+				return i + 1
+			}
+		}
+		return len(s)
+	case ']', 'P', 'X', '^', '_':
+		// OSC, DCS, SOS, PM and APC: terminated by BEL or ST
+		for i := 2; i < len(s); i++ {
+			if s[i] == 0x07 {
+				return i + 1
+			}
+			if s[i] == 0x1b && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return len(s)
+	case '(', ')', '*', '+':
+		// Character set designation
+		return min(3, len(s))
+	}
+	return 2
+}
+
+// StripANSI removes all escape sequences from s
+func StripANSI(s string) string {
+	if strings.IndexByte(s, 0x1b) < 0 {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		if n := escapeLen(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		sb.WriteByte(s[i])
+		i++
+	}
+	return sb.String()
+}
+
+// StringWidth returns the number of cells s occupies on screen. Escape
+// sequences, including OSC 8 hyperlinks and their URLs, take no space.
+func StringWidth(s string) int {
+	if strings.IndexByte(s, 0x1b) < 0 {
+		return runewidth.StringWidth(s)
+	}
+
+	width := 0
+	for i := 0; i < len(s); {
+		if n := escapeLen(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		end := i + 1
+		for end < len(s) && s[end] != 0x1b {
+			end++
+		}
+		width += runewidth.StringWidth(s[i:end])
+		i = end
+	}
+	return width
+}
+
+// Truncate shortens s to at most width cells, ending it with tail (e.g. "…")
+// when anything was cut. Escape sequences after the cut are kept so that
+// hyperlinks are closed and styles reset as in the original.
+func Truncate(s string, width int, tail string) string {
+	if StringWidth(s) <= width {
+		return s
+	}
+	target := width - StringWidth(tail)
+	if target < 0 {
+		target, tail = width, ""
+	}
+
+	var sb strings.Builder
+	used := 0
+	cut := false
+	for i := 0; i < len(s); {
+		if n := escapeLen(s[i:]); n > 0 {
+			sb.WriteString(s[i : i+n])
+			i += n
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		if cut {
+			continue
+		}
+		w := runewidth.RuneWidth(r)
+		if used+w > target {
+			sb.WriteString(tail)
+			cut = true
+			continue
+		}
+		sb.WriteRune(r)
+		used += w
+	}
+	return sb.String()
+}
+
+// Hyperlink returns text linked to url with OSC 8. Terminals without support
+// show the text alone.
+func Hyperlink(url, text string) string {
+	return "\033]8;;" + url + "\033\\" + text + "\033]8;;\033\\"
+}
+
+// openHyperlink returns the OSC 8 sequence of the hyperlink still open at the
+// end of the given lines, or "" when none is
+func openHyperlink(lines []string) string {
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := lines[i]
+		start := strings.LastIndex(line, "\033]8;")
+		if start < 0 {
+			continue
+		}
+		seq := line[start : start+escapeLen(line[start:])]
+		// OSC 8 ; params ; uri, where an empty uri closes the link
+		body := strings.TrimRight(strings.TrimPrefix(seq, "\033]8;"), "\a\033\\")
+		if _, uri, _ := strings.Cut(body, ";"); uri != "" {
+			return seq
+		}
+		return ""
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package brew
+
+import "testing"
+
+func TestStringWidth(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"日本", 4},
+		{"é", 1},
+		{"\x1b[1mab\x1b[m", 2},
+		{"\x1b[38;2;1;2;3m日\x1b[m!", 3},
+		{Hyperlink("http://example.com/a/long/path", "hi"), 2},
+		{"\x1b]8;;http://example.com\x07hi\x1b]8;;\x07", 2},
+		{"a\x1b", 1},
+	}
+	for _, tt := range tests {
+		if got := StringWidth(tt.in); got != tt.want {
+			t.Errorf("StringWidth(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		width int
+		tail  string
+		want  string
+	}{
+		{"fits", "hello", 5, "…", "hello"},
+		{"cut with tail", "hello", 4, "…", "hel…"},
+		{"cut without tail", "hello", 2, "", "he"},
+		{"tail wider than width", "hello", 2, "...", "he"},
+		{"zero width", "hello", 0, "", ""},
+		{"wide characters", "日本語", 5, "…", "日本…"},
+		{"wide character does not fit", "日本語", 3, "", "日"},
+		{"styles are kept", "\x1b[31mhello\x1b[m", 3, "…", "\x1b[31mhe…\x1b[m"},
+		{"hyperlink is closed", Hyperlink("http://a", "link text"), 4, "…", "\x1b]8;;http://a\x1b\\lin…\x1b]8;;\x1b\\"},
+		{"escapes only count once", "ab\x1b[1mcd\x1b[mef", 4, "", "ab\x1b[1mcd\x1b[m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Truncate(tt.in, tt.width, tt.tail)
+			if got != tt.want {
+				t.Errorf("Truncate(%q, %d, %q) = %q, want %q", tt.in, tt.width, tt.tail, got, tt.want)
+			}
+			if w := StringWidth(got); w > tt.width {
+				t.Errorf("result is %d cells wide, more than %d", w, tt.width)
+			}
+		})
+	}
+}
+
+func TestOpenHyperlink(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{"no lines", nil, ""},
+		{"no link", []string{"a", "b"}, ""},
+		{"closed link", []string{Hyperlink("http://a", "x")}, ""},
+		{"open link", []string{"\x1b]8;;http://a\x1b\\text"}, "\x1b]8;;http://a\x1b\\"},
+		{"open on an earlier line", []string{"\x1b]8;id=1;http://a\x1b\\x", "more"}, "\x1b]8;id=1;http://a\x1b\\"},
+		{"BEL terminated", []string{"\x1b]8;;http://a\x07x"}, "\x1b]8;;http://a\x07"},
+		{"closed on a later line", []string{"\x1b]8;;http://a\x1b\\x", "y\x1b]8;;\x1b\\"}, ""},
+		{"second link open", []string{Hyperlink("http://a", "x") + "\x1b]8;;http://b\x1b\\y"}, "\x1b]8;;http://b\x1b\\"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := openHyperlink(tt.lines); got != tt.want {
+				t.Errorf("openHyperlink(%q) = %q, want %q", tt.lines, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+// Package brewtest records the output of coldbrew renders for a scripted
+// session and compares it against golden files.
+//
+// A Session renders a model through a brew.Terminal into a
+// brew.VirtualTerminal. Every step keeps the exact bytes that were written
+// together with the resulting screen and scrollback, so a regression in the
+// differential renderer shows up as a readable diff of screen states.
+//
+// Run the tests with COLDBREW_UPDATE_GOLDEN=1 to regenerate the golden files,
+// or call SetUpdate from a flag of the test package.
+package brewtest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	brew "github.com/jpoz/coldbrew"
+	"github.com/mattn/go-runewidth"
+)
+
+// update makes AssertGolden rewrite the golden files
+var update atomic.Bool
+
+func init() {
+	update.Store(os.Getenv("COLDBREW_UPDATE_GOLDEN") != "")
+}
+
+// SetUpdate makes AssertGolden rewrite the golden files instead of comparing
+// against them, e.g. when the test package's own -update flag is set
+func SetUpdate(enable bool) {
+	update.Store(enable)
+}
+
+// Session drives a model through the coldbrew render path
+type Session struct {
+	model    tea.Model
+	screen   *brew.VirtualTerminal
+	terminal *brew.Terminal
+	pending  bytes.Buffer
+	stream   bytes.Buffer
+	steps    []step
+}
+
+// step is a single recorded frame
+type step struct {
+	label      string
+	output     []byte
+	screen     []string
+	scrollback []string
+	cursor     brew.Position
+	visible    bool
+}
+
+// NewSession creates a session rendering into a virtual terminal of the given
+// size. The model is initialised and rendered once; commands returned by the
+// model are not executed, send the messages they would produce instead.
+func NewSession(model tea.Model, width, height int) *Session {
+	s := NewTerminalSession(width, height)
+	s.model = model
+	model.Init()
+	s.Frame("init", model.View())
+	return s
+}
+
+// NewTerminalSession creates a session without a model, for scripting frames
+// directly with Frame
+// The terminal renders with truecolor and no synchronized output regardless of
+// the environment, so transcripts are stable; use Terminal to change that.
+func NewTerminalSession(width, height int) *Session {
+	s := &Session{screen: brew.NewVirtualTerminal(width, height)}
+	s.terminal = brew.NewTerminal().
+		WithOutput(&s.pending).
+		WithOutputMode(brew.OutputInteractive).
+		WithCapabilities(brew.Capabilities{Profile: brew.ProfileTrueColor, FocusReporting: true}).
+		WithSizeFunc(func() (brew.Size, error) { return s.screen.Size(), nil })
+	return s
+}
+
+// Send updates the model with msg and renders the resulting view
+func (s *Session) Send(msg tea.Msg) {
+	if s.model == nil {
+		panic("brewtest: Send called on a session without a model")
+	}
+	s.model, _ = s.model.Update(msg)
+	s.Frame(fmt.Sprintf("%T", msg), s.model.View())
+}
+
+// Resize changes the virtual terminal size and, when the session has a model,
+// delivers the matching tea.WindowSizeMsg
+func (s *Session) Resize(width, height int) {
+	s.screen.Resize(width, height)
+	if s.model != nil {
+		s.Send(tea.WindowSizeMsg{Width: width, Height: height})
+	}
+}
+
+// Frame renders view through the terminal and records the step. When the
+// model is a brew.CursorModel the cursor is placed as the program would.
+func (s *Session) Frame(label, view string) {
+	var cursor *brew.Position
+	if cm, ok := s.model.(brew.CursorModel); ok {
+		if pos, visible := cm.Cursor(); visible {
+			cursor = &pos
+		}
+	}
+	s.terminal.SetCursor(cursor)
+	s.terminal.RenderString(view)
+	s.flush(label)
+}
+
+// Commit prints text above the inline frame, as a program's Println does, and
+// records the step
+func (s *Session) Commit(label, text string) {
+	s.terminal.Commit(text)
+	s.flush(label)
+}
+
+// Write sends raw bytes to the terminal output, e.g. to simulate output from
+// something other than the renderer, and records the step
+func (s *Session) Write(label string, p []byte) {
+	s.pending.Write(p)
+	s.flush(label)
+}
+
+// flush feeds the pending output into the emulator and records a step
+func (s *Session) flush(label string) {
+	output := bytes.Clone(s.pending.Bytes())
+	s.pending.Reset()
+	s.stream.Write(output)
+	s.screen.Write(output)
+
+	s.steps = append(s.steps, step{
+		label:      label,
+		output:     output,
+		screen:     s.screen.Screen(),
+		scrollback: s.screen.Scrollback(),
+		cursor:     s.screen.Cursor(),
+		visible:    s.screen.CursorVisible(),
+	})
+}
+
+// Terminal returns the terminal the session renders through
+func (s *Session) Terminal() *brew.Terminal {
+	return s.terminal
+}
+
+// Model returns the current model
+func (s *Session) Model() tea.Model {
+	return s.model
+}
+
+// Screen returns the emulated terminal
+func (s *Session) Screen() *brew.VirtualTerminal {
+	return s.screen
+}
+
+// Bytes returns everything written to the terminal so far
+func (s *Session) Bytes() []byte {
+	return s.stream.Bytes()
+}
+
+// Snapshot returns the readable transcript compared against golden files
+func (s *Session) Snapshot() string {
+	var sb strings.Builder
+	width := s.screen.Size().Width
+
+	for i, st := range s.steps {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "=== step %d: %s\n", i+1, st.label)
+
+		sb.WriteString("--- output\n")
+		for _, chunk := range splitAfterNewlines(st.output) {
+			fmt.Fprintf(&sb, "%q\n", chunk)
+		}
+
+		if len(st.scrollback) > 0 {
+			sb.WriteString("--- scrollback\n")
+			for _, line := range st.scrollback {
+				fmt.Fprintf(&sb, "|%s|\n", pad(line, width))
+			}
+		}
+
+		fmt.Fprintf(&sb, "--- screen (cursor %d,%d visible=%t)\n", st.cursor.X, st.cursor.Y, st.visible)
+		for _, line := range st.screen {
+			fmt.Fprintf(&sb, "|%s|\n", pad(line, width))
+		}
+	}
+	return sb.String()
+}
+
+// AssertGolden compares the session transcript with testdata/<name>.golden,
+// rewriting the file instead when updating is enabled
+func (s *Session) AssertGolden(tb testing.TB, name string) {
+	tb.Helper()
+	AssertGolden(tb, name, s.Snapshot())
+}
+
+// AssertGolden compares got with testdata/<name>.golden, rewriting the file
+// instead when updating is enabled
+func AssertGolden(tb testing.TB, name, got string) {
+	tb.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if update.Load() {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			tb.Fatalf("brewtest: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			tb.Fatalf("brewtest: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		tb.Fatalf("brewtest: %v (run with COLDBREW_UPDATE_GOLDEN=1 to create it)", err)
+	}
+	if string(want) != got {
+		tb.Errorf("brewtest: %s does not match (run with COLDBREW_UPDATE_GOLDEN=1 to accept)\n%s", path, Diff(string(want), got))
+	}
+}
+
+// splitAfterNewlines breaks output into chunks ending at each newline so the
+// quoted form stays readable
+func splitAfterNewlines(b []byte) []string {
+	if len(b) == 0 {
+		return []string{""}
+	}
+	var chunks []string
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			chunks = append(chunks, string(b))
+			break
+		}
+		chunks = append(chunks, string(b[:i+1]))
+		b = b[i+1:]
+	}
+	return chunks
+}
+
+// pad right-pads a screen row with spaces to the given cell width
+func pad(line string, width int) string {
+	if n := width - runewidth.StringWidth(line); n > 0 {
+		return line + strings.Repeat(" ", n)
+	}
+	return line
+}
@@ -0,0 +1,150 @@
+package brewtest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	brew "github.com/jpoz/coldbrew"
+)
+
+// linesModel views whatever lines it was last sent
+type linesModel struct {
+	lines []string
+}
+
+// setLines replaces the lines of a linesModel
+type setLines []string
+
+func (m linesModel) Init() tea.Cmd { return nil }
+
+func (m linesModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if lines, ok := msg.(setLines); ok {
+		m.lines = lines
+	}
+	return m, nil
+}
+
+func (m linesModel) View() string {
+	return strings.Join(m.lines, "\n")
+}
+
+func TestInitialFrame(t *testing.T) {
+	s := NewSession(linesModel{lines: []string{"first", "second", "third"}}, 20, 5)
+	s.AssertGolden(t, "initial")
+}
+
+func TestDiff(t *testing.T) {
+	s := NewSession(linesModel{lines: []string{"one", "two", "three", "four"}}, 20, 6)
+	s.Send(setLines{"one", "two", "THREE", "four"})
+	s.Send(setLines{"one", "two", "THREE", "four"})
+	s.Send(setLines{"uno", "two", "THREE", "four"})
+	s.AssertGolden(t, "diff")
+}
+
+func TestShrink(t *testing.T) {
+	s := NewSession(linesModel{lines: []string{"a", "b", "c", "d"}}, 20, 6)
+	s.Send(setLines{"a", "b"})
+	s.Send(setLines{"a"})
+	s.AssertGolden(t, "shrink")
+}
+
+func TestGrowPastHeight(t *testing.T) {
+	s := NewSession(linesModel{lines: []string{"1", "2"}}, 20, 4)
+	s.Send(setLines{"1", "2", "3", "4", "5", "6"})
+	s.Send(setLines{"1", "2", "3", "4", "5", "six"})
+	s.Send(setLines{"1", "two", "3", "4", "5", "six"})
+	s.Send(setLines{"1", "2"})
+	s.AssertGolden(t, "grow_past_height")
+}
+
+func TestResize(t *testing.T) {
+	s := NewSession(linesModel{lines: []string{"a long first line", "second"}}, 20, 5)
+	s.Resize(10, 5)
+	s.Send(setLines{"short", "second"})
+	s.Resize(30, 3)
+	s.AssertGolden(t, "resize")
+}
+
+func TestCommit(t *testing.T) {
+	s := NewSession(linesModel{lines: []string{"status: 0", "footer"}}, 20, 5)
+	s.Commit("commit", "log line 1")
+	s.Send(setLines{"status: 1", "footer"})
+	s.Commit("commit", "log line 2\nlog line 3")
+	s.Commit("commit", "log line 4")
+	s.AssertGolden(t, "commit")
+}
+
+// cursorModel is a linesModel that declares a cursor
+type cursorModel struct {
+	linesModel
+	cursor *brew.Position
+}
+
+// setCursor moves the cursor of a cursorModel, or removes it when nil
+type setCursor struct {
+	pos *brew.Position
+}
+
+func (m cursorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if c, ok := msg.(setCursor); ok {
+		m.cursor = c.pos
+		return m, nil
+	}
+	updated, cmd := m.linesModel.Update(msg)
+	m.linesModel = updated.(linesModel)
+	return m, cmd
+}
+
+func (m cursorModel) Cursor() (brew.Position, bool) {
+	if m.cursor == nil {
+		return brew.Position{}, false
+	}
+	return *m.cursor, true
+}
+
+func TestCursor(t *testing.T) {
+	for _, hidden := range []bool{false, true} {
+		name := "cursor_shown"
+		if hidden {
+			name = "cursor_hidden"
+		}
+		t.Run(name, func(t *testing.T) {
+			model := cursorModel{
+				linesModel: linesModel{lines: []string{"name: ", "footer"}},
+				cursor:     &brew.Position{X: 6, Y: 0},
+			}
+			// Programs hide the cursor before the first frame
+			s := NewTerminalSession(20, 4)
+			if hidden {
+				s.Terminal().HideCursor()
+			}
+			s.model = model
+			s.Frame("init", model.View())
+			s.Send(setLines{"name: ", "footer"})
+			s.Send(setLines{"name: a", "footer"})
+			s.Send(setCursor{&brew.Position{X: 7, Y: 0}})
+			s.Send(setCursor{nil})
+			s.AssertGolden(t, name)
+		})
+	}
+}
+
+func TestSetUpdate(t *testing.T) {
+	t.Chdir(t.TempDir())
+	defer SetUpdate(update.Load())
+	SetUpdate(true)
+	AssertGolden(t, "written", "contents\n")
+	SetUpdate(false)
+
+	got, err := os.ReadFile(filepath.Join("testdata", "written.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "contents\n" {
+		t.Errorf("golden file = %q, want %q", got, "contents\n")
+	}
+	AssertGolden(t, "written", "contents\n")
+}
@@ -0,0 +1,78 @@
+package brewtest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// Diff returns a line-based unified diff between want and got
+func Diff(want, got string) string {
+	a := strings.Split(want, "\n")
+	b := strings.Split(got, "\n")
+
+	// Longest common subsequence table
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// Walk the table producing an edit script
+	type edit struct {
+		op   byte
+		line string
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', a[i]})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j]})
+			j++
+		}
+	}
+
+	// Only keep changes and their surrounding context
+	show := make([]bool, len(edits))
+	for k, e := range edits {
+		if e.op == ' ' {
+			continue
+		}
+		for c := max(0, k-diffContext); c < min(len(edits), k+diffContext+1); c++ {
+			show[c] = true
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("--- want\n+++ got\n")
+	lastShown := -1
+	for k, e := range edits {
+		if !show[k] {
+			continue
+		}
+		if lastShown >= 0 && k > lastShown+1 {
+			sb.WriteString("...\n")
+		}
+		fmt.Fprintf(&sb, "%c%s\n", e.op, e.line)
+		lastShown = k
+	}
+	return sb.String()
+}
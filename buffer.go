@@ -0,0 +1,292 @@
+package brew
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// RenderBuffer is a grid of styled cells that components draw into. It is
+// turned into text with the shortest escape sequences that reproduce the
+// styles, so components never splice ANSI strings by hand.
+//
+// Cells that were never drawn are transparent: they show as blanks, and let
+// the cells below show through when the buffer is drawn onto another one.
+type RenderBuffer struct {
+	grid   *cellGrid
+	x, y   int // origin within the grid
+	width  int
+	height int
+}
+
+// cellGrid holds the cells shared by a buffer and its regions
+type cellGrid struct {
+	width int
+	cells []Cell
+	drawn []bool
+}
+
+// NewRenderBuffer creates a transparent buffer of the given size
+func NewRenderBuffer(width, height int) *RenderBuffer {
+	width, height = max(width, 0), max(height, 0)
+	return &RenderBuffer{
+		grid: &cellGrid{
+			width: width,
+			cells: make([]Cell, width*height),
+			drawn: make([]bool, width*height),
+		},
+		width:  width,
+		height: height,
+	}
+}
+
+// Size returns the dimensions of the buffer
+func (b *RenderBuffer) Size() Size {
+	return Size{Width: b.width, Height: b.height}
+}
+
+// Region returns a view of the rectangle at x, y, clipped to the buffer.
+// Drawing into the region draws into the buffer.
+func (b *RenderBuffer) Region(x, y, width, height int) *RenderBuffer {
+	x, y = clamp(x, 0, b.width), clamp(y, 0, b.height)
+	return &RenderBuffer{
+		grid:   b.grid,
+		x:      b.x + x,
+		y:      b.y + y,
+		width:  clamp(width, 0, b.width-x),
+		height: clamp(height, 0, b.height-y),
+	}
+}
+
+// Cell returns the cell at x, y and whether it was drawn
+func (b *RenderBuffer) Cell(x, y int) (Cell, bool) {
+	if !b.contains(x, y) {
+		return Cell{}, false
+	}
+	i := b.index(x, y)
+	return b.grid.cells[i], b.grid.drawn[i]
+}
+
+// SetCell draws c at x, y. A wide character takes the following cell too, or
+// is replaced by a blank when it does not fit.
+func (b *RenderBuffer) SetCell(x, y int, c Cell) {
+	if !b.contains(x, y) {
+		return
+	}
+	if c.Width == 2 && x+1 >= b.width {
+		c.Rune, c.Width = ' ', 1
+	}
+	if c.Width != 2 {
+		c.Width = 1
+	}
+
+	b.breakWide(x, y)
+	b.put(x, y, c)
+	if c.Width == 2 {
+		b.breakWide(x+1, y)
+		b.put(x+1, y, Cell{Fg: c.Fg, Bg: c.Bg, Attrs: c.Attrs, Link: c.Link})
+	}
+}
+
+// Fill draws c over the whole buffer
+func (b *RenderBuffer) Fill(c Cell) {
+	for y := 0; y < b.height; y++ {
+		for x := 0; x < b.width; x++ {
+			b.SetCell(x, y, c)
+		}
+	}
+}
+
+// DrawString draws s starting at x, y in the style of pen, clipping at the
+// edge of the buffer. SGR sequences and OSC 8 hyperlinks in s change the pen,
+// with a reset returning to the given pen; other escape sequences are
+// ignored. A newline continues at column x of the next row. It returns the
+// column after the last cell drawn.
+func (b *RenderBuffer) DrawString(x, y int, s string, pen Cell) int {
+	base := pen
+	col := x
+	for i := 0; i < len(s); {
+		if n := escapeLen(s[i:]); n > 0 {
+			applyEscape(&pen, s[i:i+n], base)
+			i += n
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		if r == '\n' {
+			y++
+			col = x
+			continue
+		}
+
+		w := runewidth.RuneWidth(r)
+		if w == 0 {
+			// Combining characters are not tracked separately
+			continue
+		}
+		c := pen
+		c.Rune, c.Width = r, w
+		b.SetCell(col, y, c)
+		col += w
+	}
+	return col
+}
+
+// Draw composites src onto the buffer at x, y. Transparent cells of src leave
+// the cells below unchanged, so buffers can be stacked as layers.
+func (b *RenderBuffer) Draw(src *RenderBuffer, x, y int) {
+	for sy := 0; sy < src.height; sy++ {
+		for sx := 0; sx < src.width; sx++ {
+			c, drawn := src.Cell(sx, sy)
+			if !drawn || c.Width == 0 {
+				continue
+			}
+			b.SetCell(x+sx, y+sy, c)
+		}
+	}
+}
+
+// Lines serializes each row, switching styles with as few SGR parameters as
+// possible and ending every row in the default style
+func (b *RenderBuffer) Lines() []string {
+	lines := make([]string, b.height)
+	for y := range lines {
+		var sb strings.Builder
+		var pen Cell
+		for x := 0; x < b.width; x++ {
+			c, drawn := b.Cell(x, y)
+			if drawn && c.Width == 0 && x > 0 {
+				// Second half of a wide character
+				continue
+			}
+			if !drawn {
+				c = Cell{}
+			}
+			if c.Rune == 0 {
+				c.Rune = ' '
+			}
+
+			sb.WriteString(styleTransition(pen, c))
+			pen = c
+			sb.WriteRune(c.Rune)
+		}
+		sb.WriteString(styleTransition(pen, Cell{}))
+		lines[y] = sb.String()
+	}
+	return lines
+}
+
+// String serializes the buffer as lines joined by newlines, ready to be
+// returned from View
+func (b *RenderBuffer) String() string {
+	return strings.Join(b.Lines(), "\n")
+}
+
+// contains reports whether x, y lies within the buffer
+func (b *RenderBuffer) contains(x, y int) bool {
+	return x >= 0 && y >= 0 && x < b.width && y < b.height
+}
+
+// index returns the position of x, y in the shared grid
+func (b *RenderBuffer) index(x, y int) int {
+	return (b.y+y)*b.grid.width + b.x + x
+}
+
+// put stores a cell without any wide character handling
+func (b *RenderBuffer) put(x, y int, c Cell) {
+	i := b.index(x, y)
+	b.grid.cells[i] = c
+	b.grid.drawn[i] = true
+}
+
+// breakWide blanks the other half of a wide character covering x, y, which
+// is about to be overwritten. It looks across the region edges, since the
+// character may straddle them.
+func (b *RenderBuffer) breakWide(x, y int) {
+	g := b.grid
+	gx, i := b.x+x, b.index(x, y)
+	c := g.cells[i]
+
+	switch {
+	case c.Width == 2 && gx+1 < g.width:
+		g.cells[i+1] = Cell{Rune: ' ', Width: 1, Fg: c.Fg, Bg: c.Bg, Attrs: c.Attrs}
+	case c.Width == 0 && g.drawn[i] && gx > 0 && g.cells[i-1].Width == 2:
+		lead := g.cells[i-1]
+		g.cells[i-1] = Cell{Rune: ' ', Width: 1, Fg: lead.Fg, Bg: lead.Bg, Attrs: lead.Attrs}
+	}
+}
+
+// applyEscape updates pen for the SGR and OSC 8 sequences in seq
+func applyEscape(pen *Cell, seq string, base Cell) {
+	switch {
+	case len(seq) >= 3 && seq[1] == '[' && seq[len(seq)-1] == 'm':
+		pen.applySGR(parseParams(seq[2:len(seq)-1]), base)
+	case strings.HasPrefix(seq, "\033]8;"):
+		body := strings.TrimSuffix(strings.TrimSuffix(seq[4:], "\a"), "\033\\")
+		_, pen.Link, _ = strings.Cut(body, ";")
+	}
+}
+
+// sgrAttrs lists the SGR parameters that switch each attribute on and off
+var sgrAttrs = []struct {
+	attr    Attr
+	on, off string
+}{
+	{AttrBold, "1", "22"},
+	{AttrFaint, "2", "22"},
+	{AttrItalic, "3", "23"},
+	{AttrUnderline, "4", "24"},
+	{AttrBlink, "5", "25"},
+	{AttrReverse, "7", "27"},
+	{AttrConceal, "8", "28"},
+	{AttrStrikethrough, "9", "29"},
+}
+
+// styleTransition returns the escape sequences changing the style of from
+// into the style of to
+func styleTransition(from, to Cell) string {
+	var sb strings.Builder
+	if from.Link != to.Link {
+		sb.WriteString("\033]8;;" + to.Link + "\033\\")
+	}
+
+	if from.Fg == to.Fg && from.Bg == to.Bg && from.Attrs == to.Attrs {
+		return sb.String()
+	}
+	if to.Fg.Kind == ColorKindDefault && to.Bg.Kind == ColorKindDefault && to.Attrs == 0 {
+		sb.WriteString("\033[m")
+		return sb.String()
+	}
+
+	var params []string
+	on := to.Attrs &^ from.Attrs
+	if off := from.Attrs &^ to.Attrs; off != 0 {
+		// Bold and faint share their off switch
+		if off&(AttrBold|AttrFaint) != 0 {
+			on |= to.Attrs & (AttrBold | AttrFaint)
+		}
+		seen := map[string]bool{}
+		for _, a := range sgrAttrs {
+			if off&a.attr != 0 && !seen[a.off] {
+				params = append(params, a.off)
+				seen[a.off] = true
+			}
+		}
+	}
+	for _, a := range sgrAttrs {
+		if on&a.attr != 0 {
+			params = append(params, a.on)
+		}
+	}
+	if from.Fg != to.Fg {
+		params = append(params, to.Fg.sgrParams(false))
+	}
+	if from.Bg != to.Bg {
+		params = append(params, to.Bg.sgrParams(true))
+	}
+
+	sb.WriteString("\033[" + strings.Join(params, ";") + "m")
+	return sb.String()
+}
@@ -0,0 +1,164 @@
+package brew
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStyleTransition(t *testing.T) {
+	red, green := BasicColor(1), BasicColor(2)
+	tests := []struct {
+		name     string
+		from, to Cell
+		want     string
+	}{
+		{"unchanged", Cell{Fg: red, Attrs: AttrBold}, Cell{Fg: red, Attrs: AttrBold}, ""},
+		{"attribute on", Cell{}, Cell{Attrs: AttrBold}, "\x1b[1m"},
+		{"back to default", Cell{Fg: red, Attrs: AttrBold | AttrItalic}, Cell{}, "\x1b[m"},
+		{"foreground only", Cell{Fg: red, Attrs: AttrBold}, Cell{Fg: green, Attrs: AttrBold}, "\x1b[32m"},
+		{"attribute off", Cell{Fg: red, Attrs: AttrItalic | AttrUnderline}, Cell{Fg: red, Attrs: AttrUnderline}, "\x1b[23m"},
+		{"bold to faint", Cell{Attrs: AttrBold}, Cell{Attrs: AttrFaint}, "\x1b[22;2m"},
+		{"faint kept when bold goes", Cell{Attrs: AttrBold | AttrFaint}, Cell{Attrs: AttrFaint}, "\x1b[22;2m"},
+		{"bright foreground", Cell{}, Cell{Fg: BasicColor(9)}, "\x1b[91m"},
+		{"indexed background", Cell{Fg: red}, Cell{Fg: red, Bg: IndexedColor(200)}, "\x1b[48;5;200m"},
+		{"truecolor foreground", Cell{}, Cell{Fg: RGBColor(1, 2, 3)}, "\x1b[38;2;1;2;3m"},
+		{"default foreground kept background", Cell{Fg: red, Bg: green}, Cell{Bg: green}, "\x1b[39m"},
+		{"link", Cell{}, Cell{Link: "http://example.com"}, "\x1b]8;;http://example.com\x1b\\"},
+		{"link end", Cell{Link: "http://example.com", Fg: red}, Cell{}, "\x1b]8;;\x1b\\\x1b[m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := styleTransition(tt.from, tt.to); got != tt.want {
+				t.Errorf("styleTransition = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderBufferLines(t *testing.T) {
+	b := NewRenderBuffer(6, 2)
+	b.DrawString(0, 0, "ab", Cell{Fg: BasicColor(1)})
+	b.DrawString(2, 0, "c", Cell{Fg: BasicColor(1), Attrs: AttrBold})
+	b.DrawString(1, 1, "日", Cell{})
+
+	want := []string{"\x1b[31mab\x1b[1mc\x1b[m   ", " 日   "}
+	if got := b.Lines(); !slices.Equal(got, want) {
+		t.Errorf("Lines() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderBufferBreakWide(t *testing.T) {
+	tests := []struct {
+		name  string
+		x     int
+		want  string
+		width int
+	}{
+		{"over the leading half", 0, "x   ", 4},
+		{"over the trailing half", 1, " x  ", 4},
+		{"next to the character", 2, "日x ", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewRenderBuffer(tt.width, 1)
+			b.DrawString(0, 0, "日", Cell{})
+			b.SetCell(tt.x, 0, Cell{Rune: 'x'})
+			if got := b.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			for x := range tt.width {
+				if c, drawn := b.Cell(x, 0); drawn && c.Width == 0 && (x == 0 || b.grid.cells[x-1].Width != 2) {
+					t.Errorf("orphaned continuation at %d", x)
+				}
+			}
+		})
+	}
+
+	// A region breaks characters straddling its edge
+	b := NewRenderBuffer(4, 1)
+	b.DrawString(0, 0, "日", Cell{})
+	b.Region(1, 0, 3, 1).SetCell(0, 0, Cell{Rune: 'x'})
+	if got, want := b.String(), " x  "; got != want {
+		t.Errorf("region String() = %q, want %q", got, want)
+	}
+
+	// A wide character that does not fit becomes a blank
+	b = NewRenderBuffer(3, 1)
+	b.DrawString(2, 0, "日", Cell{})
+	if got, want := b.String(), "   "; got != want {
+		t.Errorf("clipped String() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderBufferDraw(t *testing.T) {
+	bg := BasicColor(4)
+	dst := NewRenderBuffer(5, 2)
+	dst.Fill(Cell{Rune: '.', Bg: bg})
+
+	src := NewRenderBuffer(3, 2)
+	src.DrawString(0, 0, "ab", Cell{})
+	src.DrawString(1, 1, "日", Cell{})
+
+	// Undrawn cells of src are transparent, and src is clipped at the edge
+	dst.Draw(src, 3, 0)
+	want := []string{"...ab", ".... "}
+	for y, line := range want {
+		for x, r := range line {
+			c, _ := dst.Cell(x, y)
+			if c.Rune != r {
+				t.Errorf("cell %d,%d = %q, want %q", x, y, c.Rune, r)
+			}
+		}
+	}
+	if c, _ := dst.Cell(2, 0); c.Bg != bg {
+		t.Errorf("transparent cell lost its background: %+v", c)
+	}
+	// The wide character at the edge no longer fits
+	if c, _ := dst.Cell(4, 1); c.Rune != ' ' || c.Width != 1 {
+		t.Errorf("clipped wide character = %+v, want a blank", c)
+	}
+
+	dst.Draw(src, 0, 0)
+	if got, want := dst.Lines()[1], "\x1b[44m.\x1b[m日\x1b[44m.\x1b[m "; got != want {
+		t.Errorf("row 1 = %q, want %q", got, want)
+	}
+}
+
+func TestDrawBorder(t *testing.T) {
+	b := NewRenderBuffer(4, 3)
+	drawBorder(Style{Border: true, BorderColor: BasicColor(2), RoundedBorder: true}, b)
+	want := []string{"╭──╮", "│  │", "╰──╯"}
+	for y, line := range want {
+		for x, r := range []rune(line) {
+			c, drawn := b.Cell(x, y)
+			if r == ' ' {
+				if drawn {
+					t.Errorf("inside cell %d,%d drawn", x, y)
+				}
+				continue
+			}
+			if c.Rune != r || c.Fg != BasicColor(2) {
+				t.Errorf("cell %d,%d = %q %+v, want %q in green", x, y, c.Rune, c.Fg, r)
+			}
+		}
+	}
+}
+
+func TestColorCell(t *testing.T) {
+	tests := []struct {
+		color Color
+		want  CellColor
+	}{
+		{ColorDefault, CellColor{}},
+		{ColorReset, CellColor{}},
+		{ColorRed, BasicColor(1)},
+		{ColorWhite, BasicColor(7)},
+		{ColorBrightBlack, BasicColor(8)},
+		{ColorBrightCyan, BasicColor(14)},
+	}
+	for _, tt := range tests {
+		if got := tt.color.Cell(); got != tt.want {
+			t.Errorf("%q.Cell() = %+v, want %+v", tt.color, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package brew
+
+import (
+	"os"
+	"strings"
+)
+
+// Capabilities describes what the terminal supports beyond plain text
+type Capabilities struct {
+	Profile            ColorProfile // colours that can be displayed
+	SynchronizedOutput bool         // frames can be wrapped in mode 2026
+	FocusReporting     bool         // focus in/out events (mode 1004)
+	KittyKeyboard      bool         // the kitty keyboard protocol, once the terminal confirmed it
+}
+
+// CapabilitiesMsg is sent to the model at startup with the detected
+// capabilities of the terminal, and again when negotiation with the terminal
+// changes them
+type CapabilitiesMsg struct {
+	Capabilities
+}
+
+// DetectCapabilities guesses the terminal capabilities from the environment
+// ($TERM, $COLORTERM, $TERM_PROGRAM and $NO_COLOR)
+func DetectCapabilities() Capabilities {
+	return detectCapabilities(os.Getenv)
+}
+
+// detectCapabilities implements DetectCapabilities for any environment lookup
+func detectCapabilities(getenv func(string) string) Capabilities {
+	termName := strings.ToLower(getenv("TERM"))
+	program := getenv("TERM_PROGRAM")
+
+	if termName == "dumb" {
+		return Capabilities{Profile: ProfileNoColor}
+	}
+
+	caps := Capabilities{
+		Profile: colorProfile(termName, program, getenv("COLORTERM")),
+		// The Linux console is the only common terminal without it
+		FocusReporting: termName != "" && termName != "linux",
+	}
+
+	switch {
+	case termName == "xterm-kitty", termName == "xterm-ghostty", program == "ghostty",
+		strings.HasPrefix(termName, "foot"), termName == "alacritty",
+		strings.HasPrefix(termName, "contour"), program == "WezTerm", program == "iTerm.app":
+		caps.SynchronizedOutput = true
+	}
+
+	// https://no-color.org: any non-empty value disables colour
+	if getenv("NO_COLOR") != "" {
+		caps.Profile = ProfileNoColor
+	}
+	return caps
+}
+
+// colorProfile derives the colour profile from the terminal identification
+func colorProfile(termName, program, colorTerm string) ColorProfile {
+	switch strings.ToLower(colorTerm) {
+	case "truecolor", "24bit":
+		return ProfileTrueColor
+	}
+
+	switch program {
+	case "iTerm.app", "WezTerm", "ghostty", "vscode", "Hyper":
+		return ProfileTrueColor
+	case "Apple_Terminal":
+		return ProfileANSI256
+	}
+
+	switch {
+	case termName == "":
+		return ProfileNoColor
+	case termName == "xterm-kitty", termName == "xterm-ghostty", termName == "alacritty",
+		strings.HasPrefix(termName, "foot"), strings.HasSuffix(termName, "-direct"):
+		return ProfileTrueColor
+	case strings.Contains(termName, "256color"):
+		return ProfileANSI256
+	}
+	return ProfileANSI
+}
@@ -0,0 +1,33 @@
+package brew
+
+import "testing"
+
+func TestDetectCapabilities(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want Capabilities
+	}{
+		{"no terminal", map[string]string{}, Capabilities{}},
+		{"dumb", map[string]string{"TERM": "dumb", "COLORTERM": "truecolor"}, Capabilities{}},
+		{"xterm", map[string]string{"TERM": "xterm"}, Capabilities{Profile: ProfileANSI, FocusReporting: true}},
+		{"256 colours", map[string]string{"TERM": "xterm-256color"}, Capabilities{Profile: ProfileANSI256, FocusReporting: true}},
+		{"COLORTERM", map[string]string{"TERM": "xterm-256color", "COLORTERM": "truecolor"}, Capabilities{Profile: ProfileTrueColor, FocusReporting: true}},
+		{"COLORTERM 24bit", map[string]string{"TERM": "screen", "COLORTERM": "24BIT"}, Capabilities{Profile: ProfileTrueColor, FocusReporting: true}},
+		{"direct colour", map[string]string{"TERM": "xterm-direct"}, Capabilities{Profile: ProfileTrueColor, FocusReporting: true}},
+		{"linux console", map[string]string{"TERM": "linux"}, Capabilities{Profile: ProfileANSI}},
+		{"kitty", map[string]string{"TERM": "xterm-kitty"}, Capabilities{Profile: ProfileTrueColor, FocusReporting: true, SynchronizedOutput: true}},
+		{"Apple Terminal", map[string]string{"TERM": "xterm-256color", "TERM_PROGRAM": "Apple_Terminal"}, Capabilities{Profile: ProfileANSI256, FocusReporting: true}},
+		{"WezTerm", map[string]string{"TERM": "xterm-256color", "TERM_PROGRAM": "WezTerm"}, Capabilities{Profile: ProfileTrueColor, FocusReporting: true, SynchronizedOutput: true}},
+		{"NO_COLOR", map[string]string{"TERM": "xterm-kitty", "NO_COLOR": "1"}, Capabilities{Profile: ProfileNoColor, FocusReporting: true, SynchronizedOutput: true}},
+		{"empty NO_COLOR", map[string]string{"TERM": "xterm-256color", "NO_COLOR": ""}, Capabilities{Profile: ProfileANSI256, FocusReporting: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string { return tt.env[key] }
+			if got := detectCapabilities(getenv); got != tt.want {
+				t.Errorf("detectCapabilities = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package brew
+
+import "fmt"
+
+// Coalescer is implemented by messages that can absorb the messages queued
+// behind them, so a burst of high-frequency updates reaches Update (and
+// triggers a render) once instead of once per message.
+//
+// For example a log line message can append the lines of the next one, and a
+// progress message can simply keep the latest value:
+//
+//	type progressMsg float64
+//
+//	func (m progressMsg) Coalesce(next brew.Msg) (brew.Msg, bool) {
+//		if n, ok := next.(progressMsg); ok {
+//			return n, true
+//		}
+//		return m, false
+//	}
+type Coalescer interface {
+	// Coalesce merges next into the receiver. It returns the merged message
+	// and true, or false when next must be delivered separately.
+	Coalesce(next Msg) (Msg, bool)
+}
+
+// coalesce merges msg with the messages already waiting behind it for as long
+// as they can be combined. The first message that cannot be merged is kept
+// and delivered next.
+func (p *Program) coalesce(msg Msg) Msg {
+	c, ok := msg.(Coalescer)
+	if !ok {
+		return msg
+	}
+
+	merged := 0
+	for limit := max(cap(p.msgChan), 1); merged < limit; {
+		next, ok := p.queuedMsg()
+		if !ok {
+			break
+		}
+		combined, ok := c.Coalesce(next)
+		if !ok {
+			p.pending = append(p.pending, next)
+			break
+		}
+		merged++
+		msg = combined
+		if c, ok = msg.(Coalescer); !ok {
+			break
+		}
+	}
+
+	if merged > 0 {
+		p.counters.coalesced.Add(uint64(merged))
+		if p.tracing {
+			p.trace("coalesced", "msg", fmt.Sprintf("%T", msg), "merged", merged)
+		}
+	}
+	return msg
+}
+
+// queuedMsg returns a bulk message that is already waiting without blocking
+func (p *Program) queuedMsg() (Msg, bool) {
+	select {
+	case msg := <-p.msgChan:
+		return msg, true
+	default:
+	}
+	return p.queue.pop()
+}
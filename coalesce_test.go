@@ -0,0 +1,106 @@
+package brew
+
+import (
+	"reflect"
+	"testing"
+)
+
+// latestMsg keeps only the newest of the latestMsgs queued behind it
+type latestMsg int
+
+func (m latestMsg) Coalesce(next Msg) (Msg, bool) {
+	if n, ok := next.(latestMsg); ok {
+		return n, true
+	}
+	return m, false
+}
+
+// finalMsg ends a coalescing run by merging into a message that does not
+// coalesce further
+type finalMsg string
+
+func (m finalMsg) Coalesce(next Msg) (Msg, bool) {
+	if n, ok := next.(finalMsg); ok {
+		return string(m + n), true
+	}
+	return m, false
+}
+
+func TestCoalesce(t *testing.T) {
+	tests := []struct {
+		name      string
+		queued    []Msg
+		want      []Msg
+		coalesced uint64
+	}{
+		{
+			name:   "plain messages are untouched",
+			queued: []Msg{otherMsg(1), otherMsg(2)},
+			want:   []Msg{otherMsg(1), otherMsg(2)},
+		},
+		{
+			name:      "a run merges into one message",
+			queued:    []Msg{appendMsg("a"), appendMsg("b"), appendMsg("c")},
+			want:      []Msg{appendMsg("abc")},
+			coalesced: 2,
+		},
+		{
+			name:      "a message in between ends the run",
+			queued:    []Msg{appendMsg("a"), appendMsg("b"), otherMsg(1), appendMsg("c"), appendMsg("d")},
+			want:      []Msg{appendMsg("ab"), otherMsg(1), appendMsg("cd")},
+			coalesced: 2,
+		},
+		{
+			name:      "different coalescers do not merge",
+			queued:    []Msg{latestMsg(1), latestMsg(2), appendMsg("a"), latestMsg(3)},
+			want:      []Msg{latestMsg(2), appendMsg("a"), latestMsg(3)},
+			coalesced: 1,
+		},
+		{
+			name:      "a merged message that no longer coalesces stops",
+			queued:    []Msg{finalMsg("a"), finalMsg("b"), finalMsg("c")},
+			want:      []Msg{"ab", finalMsg("c")},
+			coalesced: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProgram(nil)
+			defer p.cancel()
+			for _, msg := range tt.queued {
+				p.Send(msg)
+			}
+
+			var got []Msg
+			for len(p.msgChan) > 0 || len(p.pending) > 0 {
+				msg, _ := p.nextMsg()
+				got = append(got, p.coalesce(msg))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("delivered %v, want %v", got, tt.want)
+			}
+			if c := p.Stats().Coalesced; c != tt.coalesced {
+				t.Errorf("coalesced %d, want %d", c, tt.coalesced)
+			}
+		})
+	}
+}
+
+func TestCoalesceOverflowQueue(t *testing.T) {
+	p := NewProgram(nil)
+	defer p.cancel()
+
+	// Bulk messages from the channel and the overflow queue merge alike
+	p.Send(latestMsg(1))
+	p.subscriptionSend(OverflowDropOldest)(latestMsg(2))
+
+	// Either may be taken first, the other is merged into it
+	first, _ := p.nextMsg()
+	want := latestMsg(3) - first.(latestMsg)
+	if got := p.coalesce(first); got != want {
+		t.Errorf("coalesce(%v) = %v, want %v", first, got, want)
+	}
+	if p.Stats().Coalesced != 1 {
+		t.Errorf("coalesced %d, want 1", p.Stats().Coalesced)
+	}
+}
@@ -0,0 +1,267 @@
+package brew
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ColorProfile is the range of colours a terminal can display
+type ColorProfile int
+
+const (
+	ProfileNoColor   ColorProfile = iota // no colours, attributes only
+	ProfileANSI                          // the 16 ANSI colours
+	ProfileANSI256                       // the xterm 256 colour palette
+	ProfileTrueColor                     // 24-bit colour
+)
+
+// String returns the profile name
+func (p ColorProfile) String() string {
+	switch p {
+	case ProfileNoColor:
+		return "nocolor"
+	case ProfileANSI:
+		return "ansi"
+	case ProfileANSI256:
+		return "ansi256"
+	case ProfileTrueColor:
+		return "truecolor"
+	}
+	return "unknown"
+}
+
+// ansiPalette holds the xterm default RGB values of the 16 ANSI colours
+var ansiPalette = [16]uint32{
+	0x000000, 0xcd0000, 0x00cd00, 0xcdcd00, 0x0000ee, 0xcd00cd, 0x00cdcd, 0xe5e5e5,
+	0x7f7f7f, 0xff0000, 0x00ff00, 0xffff00, 0x5c5cff, 0xff00ff, 0x00ffff, 0xffffff,
+}
+
+// cubeLevels are the channel values of the 6x6x6 colour cube (16-231)
+var cubeLevels = [6]uint32{0x00, 0x5f, 0x87, 0xaf, 0xd7, 0xff}
+
+// RGB returns the colour as 8-bit channels. Palette colours use the xterm
+// defaults; the default colour is reported as black.
+func (c CellColor) RGB() (r, g, b uint8) {
+	var v uint32
+	switch c.Kind {
+	case ColorKindBasic:
+		v = ansiPalette[c.Value&0x0f]
+	case ColorKindIndexed:
+		v = indexedRGB(int(c.Value))
+	case ColorKindRGB:
+		v = c.Value
+	}
+	return uint8(v >> 16), uint8(v >> 8), uint8(v)
+}
+
+// indexedRGB returns the packed RGB value of a 256 colour palette index
+func indexedRGB(n int) uint32 {
+	switch {
+	case n < 16:
+		return ansiPalette[n]
+	case n < 232:
+		n -= 16
+		return cubeLevels[n/36]<<16 | cubeLevels[n/6%6]<<8 | cubeLevels[n%6]
+	default:
+		gray := uint32(8 + 10*(n-232))
+		return gray<<16 | gray<<8 | gray
+	}
+}
+
+// Convert returns the closest colour the profile can display. With
+// ProfileNoColor every colour becomes the default colour.
+func (c CellColor) Convert(profile ColorProfile) CellColor {
+	if c.Kind == ColorKindDefault {
+		return c
+	}
+	switch profile {
+	case ProfileNoColor:
+		return CellColor{}
+	case ProfileANSI:
+		if c.Kind == ColorKindBasic || (c.Kind == ColorKindIndexed && c.Value < 16) {
+			return BasicColor(int(c.Value))
+		}
+		return BasicColor(nearestColor(c, 16))
+	case ProfileANSI256:
+		if c.Kind == ColorKindRGB {
+			return IndexedColor(nearestColor(c, 256))
+		}
+	}
+	return c
+}
+
+// nearestColor returns the palette index below limit closest to c
+func nearestColor(c CellColor, limit int) int {
+	r, g, b := c.RGB()
+
+	best, bestDist := 0, -1
+	consider := func(n int) {
+		v := indexedRGB(n)
+		dr := int(r) - int(v>>16&0xff)
+		dg := int(g) - int(v>>8&0xff)
+		db := int(b) - int(v&0xff)
+		// Weighted for perceived brightness
+		dist := 3*dr*dr + 4*dg*dg + 2*db*db
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = n, dist
+		}
+	}
+
+	if limit <= 16 {
+		for n := 0; n < 16; n++ {
+			consider(n)
+		}
+		return best
+	}
+
+	// The closest cube entry and grey are the only candidates worth checking
+	level := func(v uint8) int {
+		i := 0
+		for j, l := range cubeLevels {
+			if absDiff(uint32(v), l) < absDiff(uint32(v), cubeLevels[i]) {
+				i = j
+			}
+		}
+		return i
+	}
+	consider(16 + 36*level(r) + 6*level(g) + level(b))
+	gray := (int(r) + int(g) + int(b)) / 3
+	consider(232 + clamp((gray-8+5)/10, 0, 23))
+	return best
+}
+
+// absDiff returns |a-b|
+func absDiff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// sgrParams returns the SGR parameters selecting c as the foreground or
+// background colour
+func (c CellColor) sgrParams(background bool) string {
+	base := 30
+	if background {
+		base = 40
+	}
+
+	switch c.Kind {
+	case ColorKindBasic:
+		if c.Value < 8 {
+			return strconv.Itoa(base + int(c.Value))
+		}
+		return strconv.Itoa(base + 60 + int(c.Value) - 8)
+	case ColorKindIndexed:
+		return strconv.Itoa(base+8) + ";5;" + strconv.Itoa(int(c.Value))
+	case ColorKindRGB:
+		r, g, b := c.RGB()
+		return strconv.Itoa(base+8) + ";2;" + strconv.Itoa(int(r)) + ";" + strconv.Itoa(int(g)) + ";" + strconv.Itoa(int(b))
+	}
+	return strconv.Itoa(base + 9)
+}
+
+// DownsampleANSI rewrites the SGR colour sequences in s for the given
+// profile. Other escape sequences and text attributes are left untouched.
+func DownsampleANSI(s string, profile ColorProfile) string {
+	if profile >= ProfileTrueColor || strings.IndexByte(s, 0x1b) < 0 {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		n := escapeLen(s[i:])
+		if n == 0 {
+			sb.WriteByte(s[i])
+			i++
+			continue
+		}
+
+		seq := s[i : i+n]
+		i += n
+		if len(seq) >= 3 && seq[1] == '[' && seq[len(seq)-1] == 'm' {
+			if params, ok := downsampleSGR(seq[2:len(seq)-1], profile); ok {
+				if params != "" || seq == "\x1b[m" {
+					sb.WriteString("\x1b[" + params + "m")
+				}
+				continue
+			}
+		}
+		sb.WriteString(seq)
+	}
+	return sb.String()
+}
+
+// downsampleSGR converts the parameters of a single SGR sequence. It returns
+// false when the parameters cannot be interpreted.
+func downsampleSGR(raw string, profile ColorProfile) (string, bool) {
+	if raw == "" {
+		return "", true
+	}
+
+	groups := strings.Split(raw, ";")
+	var out []string
+	for i := 0; i < len(groups); i++ {
+		group := groups[i]
+
+		// Colon separated sub-parameters carry a whole colour in one group
+		if strings.Contains(group, ":") {
+			sub := parseParams(group)
+			if len(sub) > 0 && (sub[0] == 38 || sub[0] == 48) {
+				// 38:2:<colourspace>:r:g:b has an extra, often empty, field
+				if len(sub) == 6 && sub[1] == 2 {
+					sub = append(sub[:2], sub[3:]...)
+				}
+				c, _ := extendedColor(sub, 0)
+				if p := c.Convert(profile); p.Kind != ColorKindDefault {
+					out = append(out, p.sgrParams(sub[0] == 48))
+				}
+				continue
+			}
+			out = append(out, group)
+			continue
+		}
+
+		p, err := strconv.Atoi(group)
+		if err != nil && group != "" {
+			return "", false
+		}
+
+		var c CellColor
+		background := false
+		switch {
+		case p == 38 || p == 48:
+			rest := make([]int, 0, 5)
+			for _, g := range groups[i:min(i+5, len(groups))] {
+				v, _ := strconv.Atoi(g)
+				rest = append(rest, v)
+			}
+			var last int
+			c, last = extendedColor(rest, 0)
+			i += min(last, len(groups)-1-i)
+			background = p == 48
+		case p >= 30 && p <= 37:
+			c = BasicColor(p - 30)
+		case p >= 40 && p <= 47:
+			c, background = BasicColor(p-40), true
+		case p >= 90 && p <= 97:
+			c = BasicColor(p - 90 + 8)
+		case p >= 100 && p <= 107:
+			c, background = BasicColor(p-100+8), true
+		case p == 39 || p == 49:
+			if profile == ProfileNoColor {
+				continue
+			}
+			out = append(out, group)
+			continue
+		default:
+			out = append(out, group)
+			continue
+		}
+
+		if converted := c.Convert(profile); converted.Kind != ColorKindDefault {
+			out = append(out, converted.sgrParams(background))
+		}
+	}
+	return strings.Join(out, ";"), true
+}
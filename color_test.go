@@ -0,0 +1,57 @@
+package brew
+
+import "testing"
+
+func TestDownsampleANSI(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		profile ColorProfile
+		want    string
+	}{
+		{"truecolor is untouched", "\x1b[38;2;1;2;3mx", ProfileTrueColor, "\x1b[38;2;1;2;3mx"},
+		{"plain text", "plain", ProfileNoColor, "plain"},
+		{"rgb to 256", "\x1b[38;2;255;0;0mx", ProfileANSI256, "\x1b[38;5;196mx"},
+		{"rgb to grey", "\x1b[48;2;128;128;128mx", ProfileANSI256, "\x1b[48;5;244mx"},
+		{"indexed kept in 256", "\x1b[38;5;100mx", ProfileANSI256, "\x1b[38;5;100mx"},
+		{"rgb to ansi", "\x1b[38;2;255;0;0mx", ProfileANSI, "\x1b[91mx"},
+		{"low index to ansi", "\x1b[38;5;1mx", ProfileANSI, "\x1b[31mx"},
+		{"mixed with attributes", "\x1b[1;48;5;196;4mx", ProfileANSI, "\x1b[1;101;4mx"},
+		{"colon form", "\x1b[38:2::255:0:0mx", ProfileANSI256, "\x1b[38;5;196mx"},
+		{"no colour keeps attributes", "\x1b[1;31mx\x1b[39;0m", ProfileNoColor, "\x1b[1mx\x1b[0m"},
+		{"no colour drops empty sequences", "\x1b[31mx\x1b[m", ProfileNoColor, "x\x1b[m"},
+		{"other sequences untouched", "\x1b]8;;http://a\x1b\\\x1b[2Jx", ProfileNoColor, "\x1b]8;;http://a\x1b\\\x1b[2Jx"},
+		{"unparsable parameters untouched", "\x1b[31;xmx", ProfileNoColor, "\x1b[31;xmx"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DownsampleANSI(tt.in, tt.profile); got != tt.want {
+				t.Errorf("DownsampleANSI(%q, %v) = %q, want %q", tt.in, tt.profile, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColorConvert(t *testing.T) {
+	tests := []struct {
+		color   CellColor
+		profile ColorProfile
+		want    CellColor
+	}{
+		{CellColor{}, ProfileANSI, CellColor{}},
+		{BasicColor(3), ProfileNoColor, CellColor{}},
+		{BasicColor(3), ProfileANSI, BasicColor(3)},
+		{IndexedColor(12), ProfileANSI, BasicColor(12)},
+		{IndexedColor(196), ProfileANSI, BasicColor(9)},
+		{IndexedColor(232), ProfileANSI, BasicColor(0)},
+		{RGBColor(0, 0, 0xee), ProfileANSI, BasicColor(4)},
+		{RGBColor(0xff, 0xff, 0xff), ProfileANSI256, IndexedColor(231)},
+		{RGBColor(8, 8, 8), ProfileANSI256, IndexedColor(232)},
+		{RGBColor(1, 2, 3), ProfileTrueColor, RGBColor(1, 2, 3)},
+	}
+	for _, tt := range tests {
+		if got := tt.color.Convert(tt.profile); got != tt.want {
+			t.Errorf("%+v.Convert(%v) = %+v, want %+v", tt.color, tt.profile, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package brew
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ContextCmd creates a command whose function receives a context that is
+// cancelled when the program quits or is killed. Use it for long running work
+// such as HTTP requests so it stops together with the program; the message
+// returned after cancellation is discarded. The command returns an internal
+// message that the program runs fn for, so it has no effect outside a Program.
+func ContextCmd(fn func(ctx context.Context) Msg) Cmd {
+	return func() Msg {
+		return contextCmdMsg{fn: fn}
+	}
+}
+
+// contextCmdMsg asks the runtime to run a context-aware command
+type contextCmdMsg struct {
+	fn func(ctx context.Context) Msg
+}
+
+// runCmd executes cmd in its own goroutine and sends the resulting message
+// back to the program
+func (p *Program) runCmd(cmd Cmd) {
+	if cmd == nil {
+		return
+	}
+	p.track(func() Msg { return cmd() })
+}
+
+// runContextCmd executes a context-aware command with the program context
+func (p *Program) runContextCmd(fn func(ctx context.Context) Msg) {
+	p.track(func() Msg { return fn(p.ctx) })
+}
+
+// track runs fn in its own goroutine, keeping count of in-flight commands
+func (p *Program) track(fn func() Msg) {
+	id := p.cmdSeq.Add(1)
+	p.cmds.Add(1)
+	p.inFlight.Add(1)
+
+	go func() {
+		defer p.cmds.Done()
+		defer p.inFlight.Add(-1)
+
+		start := time.Now()
+		p.trace("cmd start", "id", id)
+		msg := fn()
+		if p.tracing {
+			p.trace("cmd finish", "id", id, "duration", time.Since(start), "msg", fmt.Sprintf("%T", msg))
+		}
+		if msg != nil {
+			p.Send(msg)
+		}
+	}()
+}
+
+// InFlight returns the number of commands that have not returned yet
+func (p *Program) InFlight() int {
+	return int(p.inFlight.Load())
+}
+
+// drainCmds cancels the program context and waits up to the shutdown timeout
+// for in-flight commands to return
+func (p *Program) drainCmds() {
+	p.cancel()
+	if p.drainTimeout <= 0 {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.cmds.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(p.drainTimeout):
+		p.trace("shutdown timeout", "in_flight", p.InFlight())
+	}
+}
+
+// Debounce creates a command that sends msg once duration has passed without
+// another Debounce for the same key. Each call supersedes the pending one, so
+// e.g. search-as-you-type only fires after the user paused typing:
+//
+//	return m, brew.Debounce("search", 200*time.Millisecond, searchMsg{m.query})
+func Debounce(key string, duration time.Duration, msg Msg) Cmd {
+	return func() Msg {
+		return debounceMsg{key: key, duration: duration, msg: msg}
+	}
+}
+
+// Throttle creates a command that sends msg at most once per duration for the
+// same key. The first message is sent immediately; later ones within duration
+// are held back, and only the latest of them is sent when the window ends, so
+// the last message of a burst always arrives.
+func Throttle(key string, duration time.Duration, msg Msg) Cmd {
+	return func() Msg {
+		return throttleMsg{key: key, duration: duration, msg: msg}
+	}
+}
+
+// timedMsg is implemented by the internal debounce and throttle messages
+type timedMsg interface {
+	timedKey() string
+}
+
+// debounceMsg schedules a debounced message
+type debounceMsg struct {
+	key      string
+	duration time.Duration
+	msg      Msg
+}
+
+// debounceFireMsg is sent when a debounce timer expires
+type debounceFireMsg struct {
+	key        string
+	generation uint64
+	msg        Msg
+}
+
+// throttleMsg asks for a throttled message to be delivered
+type throttleMsg struct {
+	key      string
+	duration time.Duration
+	msg      Msg
+}
+
+// throttleFireMsg is sent when the window of a throttled key ends
+type throttleFireMsg struct {
+	key string
+}
+
+// throttleWindow is the state of a throttled key while messages for it are
+// held back
+type throttleWindow struct {
+	duration time.Duration
+	held     Msg // latest message held back, sent when the window ends
+}
+
+func (m debounceMsg) timedKey() string     { return m.key }
+func (m debounceFireMsg) timedKey() string { return m.key }
+func (m throttleMsg) timedKey() string     { return m.key }
+func (m throttleFireMsg) timedKey() string { return m.key }
+
+// handleTimed processes a debounce or throttle message and returns the
+// wrapped message when it should be delivered now
+func (p *Program) handleTimed(msg timedMsg) (Msg, bool) {
+	switch msg := msg.(type) {
+	case debounceMsg:
+		generation := p.debounces[msg.key] + 1
+		p.debounces[msg.key] = generation
+		p.runContextCmd(func(ctx context.Context) Msg {
+			timer := time.NewTimer(msg.duration)
+			defer timer.Stop()
+
+			select {
+			case <-timer.C:
+				return debounceFireMsg{key: msg.key, generation: generation, msg: msg.msg}
+			case <-ctx.Done():
+				return nil
+			}
+		})
+		return nil, false
+
+	case debounceFireMsg:
+		if p.debounces[msg.key] != msg.generation {
+			// Superseded by a later Debounce
+			return nil, false
+		}
+		delete(p.debounces, msg.key)
+		return msg.msg, true
+
+	case throttleMsg:
+		if w, open := p.throttles[msg.key]; open {
+			p.trace("throttled", "key", msg.key)
+			w.duration, w.held = msg.duration, msg.msg
+			return nil, false
+		}
+		p.throttles[msg.key] = &throttleWindow{duration: msg.duration}
+		p.startThrottleWindow(msg.key, msg.duration)
+		return msg.msg, true
+
+	case throttleFireMsg:
+		w, open := p.throttles[msg.key]
+		if !open {
+			return nil, false
+		}
+		if w.held == nil {
+			delete(p.throttles, msg.key)
+			return nil, false
+		}
+		// Sending the held message opens the next window
+		held := w.held
+		w.held = nil
+		p.startThrottleWindow(msg.key, w.duration)
+		return held, true
+	}
+	return nil, false
+}
+
+// startThrottleWindow ends the window of a throttled key after duration
+func (p *Program) startThrottleWindow(key string, duration time.Duration) {
+	p.runContextCmd(func(ctx context.Context) Msg {
+		timer := time.NewTimer(duration)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			return throttleFireMsg{key: key}
+		case <-ctx.Done():
+			return nil
+		}
+	})
+}
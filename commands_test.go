@@ -0,0 +1,96 @@
+package brew
+
+import (
+	"testing"
+	"time"
+)
+
+// nextSent returns the next message a command sent to the program
+func nextSent(t *testing.T, p *Program) Msg {
+	t.Helper()
+	select {
+	case msg := <-p.msgChan:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message sent")
+		return nil
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	p := NewProgram(nil)
+	defer p.cancel()
+	const window = 20 * time.Millisecond
+
+	throttle := func(msg Msg) (Msg, bool) {
+		return p.handleTimed(Throttle("key", window, msg)().(timedMsg))
+	}
+
+	if msg, ok := throttle(1); !ok || msg != 1 {
+		t.Fatalf("first message = %v, %t, want it sent", msg, ok)
+	}
+	for _, msg := range []Msg{2, 3} {
+		if _, ok := throttle(msg); ok {
+			t.Errorf("message %v sent within the window", msg)
+		}
+	}
+
+	// The latest held message is sent when the window ends
+	msg, ok := p.handleTimed(nextSent(t, p).(timedMsg))
+	if !ok || msg != 3 {
+		t.Fatalf("end of window = %v, %t, want 3", msg, ok)
+	}
+	if _, ok := throttle(4); ok {
+		t.Error("message sent within the window opened by the held message")
+	}
+	if msg, ok := p.handleTimed(nextSent(t, p).(timedMsg)); !ok || msg != 4 {
+		t.Fatalf("end of second window = %v, %t, want 4", msg, ok)
+	}
+
+	// A window without held messages closes and forgets the key
+	if msg, ok := p.handleTimed(nextSent(t, p).(timedMsg)); ok {
+		t.Errorf("empty window sent %v", msg)
+	}
+	if len(p.throttles) != 0 {
+		t.Errorf("%d throttle windows left open", len(p.throttles))
+	}
+	if msg, ok := throttle(5); !ok || msg != 5 {
+		t.Errorf("message after the window = %v, %t, want it sent", msg, ok)
+	}
+}
+
+func TestThrottleKeys(t *testing.T) {
+	p := NewProgram(nil)
+	defer p.cancel()
+
+	for _, key := range []string{"a", "b"} {
+		if _, ok := p.handleTimed(Throttle(key, time.Minute, key)().(timedMsg)); !ok {
+			t.Errorf("first message for %q held back", key)
+		}
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	p := NewProgram(nil)
+	defer p.cancel()
+
+	for _, msg := range []Msg{1, 2, 3} {
+		if _, ok := p.handleTimed(Debounce("key", 10*time.Millisecond, msg)().(timedMsg)); ok {
+			t.Errorf("message %v sent before the pause", msg)
+		}
+	}
+
+	// Every call started a timer, only the last one delivers
+	var delivered []Msg
+	for range 3 {
+		if msg, ok := p.handleTimed(nextSent(t, p).(timedMsg)); ok {
+			delivered = append(delivered, msg)
+		}
+	}
+	if len(delivered) != 1 || delivered[0] != 3 {
+		t.Errorf("delivered %v, want [3]", delivered)
+	}
+	if len(p.debounces) != 0 {
+		t.Errorf("%d debounce keys left", len(p.debounces))
+	}
+}
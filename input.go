@@ -2,8 +2,9 @@ package brew
 
 import (
 	"context"
+	"errors"
 	"io"
-	"os"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -41,12 +42,16 @@ const (
 	KeyF12        = tea.KeyF12
 )
 
+// defaultEscapeTimeout is how long an incomplete escape sequence is waited on.
+// Terminals write a sequence in one go, so its bytes arrive well within this.
+const defaultEscapeTimeout = 50 * time.Millisecond
+
 // handleInput handles keyboard input and sends KeyMsg messages
 func (p *Program) handleInput() {
 	if p.rawMode {
 		// Use bubbletea's own input reading for maximum compatibility
-		err := p.readInputsCompat(p.ctx, p.msgChan, os.Stdin)
-		if err != nil {
+		err := p.readInputsCompat(p.ctx, p.priority, p.input)
+		if err != nil && err != io.EOF {
 			// If enhanced reading fails, fall back to simple raw mode
 			p.handleSimpleRawInput()
 		}
@@ -56,39 +61,164 @@ func (p *Program) handleInput() {
 	}
 }
 
-// readInputsCompat uses bubbletea's own key detection logic for maximum compatibility
+// readInputsCompat decodes keys, escape sequences and terminal reports from
+// the input and sends them as messages
 func (p *Program) readInputsCompat(ctx context.Context, msgs chan<- Msg, input io.Reader) error {
-	// We'll delegate to bubbletea's input reading but intercept the messages
-	// This ensures 100% compatibility with bubbletea's key detection
-	
-	// Create a simple input reader loop that reads bytes and converts them to tea.KeyMsg
-	var buf [256]byte
+	in := newInputBytes(ctx, input)
+	var data []byte
+	var timeout *time.Timer
 
 	for {
+		// An incomplete sequence is only waited on for a short while, so a
+		// lone Esc press is not held back until the next key arrives
+		var expired <-chan time.Time
+		if timeout != nil {
+			expired = timeout.C
+		}
+
+		force := false
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		default:
-		}
-
-		// Read bytes from input
-		numBytes, err := input.Read(buf[:])
-		if err != nil {
+		case err := <-in.errs:
 			return err
+		case chunk := <-in.chunks:
+			data = append(data, chunk...)
+		case <-expired:
+			force = true
+		}
+		if timeout != nil {
+			timeout.Stop()
+			timeout = nil
 		}
 
-		// Process each byte through our simple detection
-		for i := 0; i < numBytes; i++ {
-			key := p.detectSimpleKey(buf[i])
-			if key != nil {
+		for len(data) > 0 {
+			n, msg := p.parseInput(data)
+			if n == 0 && force {
+				// Nothing completed the sequence in time
+				n, msg = expireInput(data)
+			}
+			if n == 0 {
+				break
+			}
+			data = data[n:]
+
+			if msg != nil {
 				select {
-				case msgs <- *key:
+				case msgs <- msg:
 				case <-ctx.Done():
 					return ctx.Err()
 				}
 			}
 		}
+
+		// A sequence that outlived the timeout is waited on until it completes
+		if len(data) > 0 && !force {
+			timeout = time.NewTimer(p.escTimeout)
+		}
+	}
+}
+
+// expireInput resolves input that stayed incomplete for the escape timeout.
+// A lone ESC was the Esc key and ESC ESC was alt+Esc. Sequences that began
+// with an introducer are kept buffering, as a slow or split terminal reply
+// must never turn into keystrokes; parseInput discards them once they exceed
+// their maximum length. It returns 0 when the input must keep waiting.
+func expireInput(data []byte) (int, Msg) {
+	if data[0] != 0x1b {
+		// A truncated UTF-8 character
+		return 1, nil
+	}
+	if len(data) == 1 {
+		return 1, tea.KeyMsg{Type: tea.KeyEsc}
+	}
+	if isIntroducer(data[1]) {
+		return 0, nil
+	}
+	if data[1] == 0x1b {
+		if len(data) == 2 {
+			return 2, tea.KeyMsg{Type: tea.KeyEsc, Alt: true}
+		}
+		if isIntroducer(data[2]) {
+			// alt with a sequence that is still arriving
+			return 0, nil
+		}
+	}
+	// ESC before a truncated character
+	return 1, tea.KeyMsg{Type: tea.KeyEsc}
+}
+
+// isIntroducer reports whether b following ESC starts a CSI, OSC, DCS or SS3
+// sequence
+func isIntroducer(b byte) bool {
+	return b == '[' || b == ']' || b == 'P' || b == 'O'
+}
+
+// errInputTimeout is returned by inputBytes.next when no input arrived in time
+var errInputTimeout = errors.New("brew: input timeout")
+
+// inputBytes reads the input in the background so that waiting for the next
+// byte can be bounded by a timeout
+type inputBytes struct {
+	ctx    context.Context
+	chunks chan []byte
+	errs   chan error
+	buf    []byte
+}
+
+// newInputBytes starts reading input until it fails or ctx is done
+func newInputBytes(ctx context.Context, input io.Reader) *inputBytes {
+	in := &inputBytes{
+		ctx:    ctx,
+		chunks: make(chan []byte),
+		errs:   make(chan error, 1),
+	}
+	go func() {
+		for {
+			buf := make([]byte, 256)
+			n, err := input.Read(buf)
+			if n > 0 {
+				select {
+				case in.chunks <- buf[:n]:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				in.errs <- err
+				return
+			}
+		}
+	}()
+	return in
+}
+
+// next returns the next input byte, waiting at most timeout for it when
+// timeout is positive
+func (in *inputBytes) next(timeout time.Duration) (byte, error) {
+	if len(in.buf) == 0 {
+		var expired <-chan time.Time
+		if timeout > 0 {
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			expired = timer.C
+		}
+
+		select {
+		case chunk := <-in.chunks:
+			in.buf = chunk
+		case err := <-in.errs:
+			return 0, err
+		case <-expired:
+			return 0, errInputTimeout
+		case <-in.ctx.Done():
+			return 0, in.ctx.Err()
+		}
 	}
+
+	b := in.buf[0]
+	in.buf = in.buf[1:]
+	return b, nil
 }
 
 // detectSimpleKey converts a single byte to a KeyMsg
@@ -133,7 +263,10 @@ func (p *Program) handleLineInput() {
 		case <-p.ctx.Done():
 			return
 		default:
-			n, err := os.Stdin.Read(buf)
+			n, err := p.input.Read(buf)
+			if err == io.EOF {
+				return
+			}
 			if err != nil || n == 0 {
 				continue
 			}
@@ -148,105 +281,86 @@ func (p *Program) handleLineInput() {
 
 // handleSimpleRawInput is a fallback for raw input when enhanced reading fails
 func (p *Program) handleSimpleRawInput() {
-	buf := make([]byte, 1)
+	in := newInputBytes(p.ctx, p.input)
 
 	for {
-		select {
-		case <-p.ctx.Done():
+		ch, err := in.next(0)
+		if err != nil {
 			return
-		default:
-			n, err := os.Stdin.Read(buf)
-			if err != nil || n == 0 {
-				continue
-			}
+		}
 
-			ch := buf[0]
-			var key tea.Key
-
-			switch ch {
-			case 3: // Ctrl+C
-				key = tea.Key{Type: tea.KeyCtrlC}
-			case 4: // Ctrl+D
-				key = tea.Key{Type: tea.KeyCtrlD}
-			case 10: // Enter (Line Feed) - Unix systems send LF for Enter
-				key = tea.Key{Type: tea.KeyEnter}
-			case 13: // Enter (Carriage Return) - Windows systems may send CR
-				key = tea.Key{Type: tea.KeyEnter}
-			case 27: // Escape - try to read escape sequence
-				escKey := p.readEscapeSequence()
-				key = escKey
-			case 127, 8: // Backspace
-				key = tea.Key{Type: tea.KeyBackspace}
-			case 9: // Tab
-				key = tea.Key{Type: tea.KeyTab}
-			case 32: // Space
-				key = tea.Key{Type: tea.KeySpace, Runes: []rune{' '}}
-			default:
-				if ch >= 32 && ch <= 126 { // Printable ASCII
-					key = tea.Key{Type: tea.KeyRunes, Runes: []rune{rune(ch)}}
-				} else {
-					// Control character
-					key = tea.Key{Type: tea.KeyType(ch)}
-				}
-			}
+		var key tea.Key
 
-			p.Send(tea.KeyMsg(key))
+		switch ch {
+		case 3: // Ctrl+C
+			key = tea.Key{Type: tea.KeyCtrlC}
+		case 4: // Ctrl+D
+			key = tea.Key{Type: tea.KeyCtrlD}
+		case 10: // Enter (Line Feed) - Unix systems send LF for Enter
+			key = tea.Key{Type: tea.KeyEnter}
+		case 13: // Enter (Carriage Return) - Windows systems may send CR
+			key = tea.Key{Type: tea.KeyEnter}
+		case 27: // Escape - try to read escape sequence
+			escKey := p.readEscapeSequence(in)
+			key = escKey
+		case 127, 8: // Backspace
+			key = tea.Key{Type: tea.KeyBackspace}
+		case 9: // Tab
+			key = tea.Key{Type: tea.KeyTab}
+		case 32: // Space
+			key = tea.Key{Type: tea.KeySpace, Runes: []rune{' '}}
+		default:
+			if ch >= 32 && ch <= 126 { // Printable ASCII
+				key = tea.Key{Type: tea.KeyRunes, Runes: []rune{rune(ch)}}
+			} else {
+				// Control character
+				key = tea.Key{Type: tea.KeyType(ch)}
+			}
 		}
+
+		p.Send(tea.KeyMsg(key))
 	}
 }
 
-// readEscapeSequence reads an escape sequence for simple raw input
-func (p *Program) readEscapeSequence() tea.Key {
-	buf := make([]byte, 2)
-	n, err := os.Stdin.Read(buf)
-	if err != nil || n == 0 {
+// readEscapeSequence reads an escape sequence for simple raw input. Bytes of
+// a sequence arrive together, so if none follow within the escape timeout the
+// ESC was a key press of its own.
+func (p *Program) readEscapeSequence(in *inputBytes) tea.Key {
+	b, err := in.next(p.escTimeout)
+	if err != nil {
 		return tea.Key{Type: tea.KeyEsc}
 	}
-
-	if n >= 1 && buf[0] == '[' {
-		if n >= 2 {
-			switch buf[1] {
-			case 'A':
-				return tea.Key{Type: tea.KeyUp}
-			case 'B':
-				return tea.Key{Type: tea.KeyDown}
-			case 'C':
-				return tea.Key{Type: tea.KeyRight}
-			case 'D':
-				return tea.Key{Type: tea.KeyLeft}
-			case 'I':
-				// Focus gained - send FocusMsg directly to program
-				p.Send(tea.FocusMsg{})
-				return tea.Key{Type: tea.KeyEsc} // Return escape as fallback
-			case 'O':
-				// Focus lost - send BlurMsg directly to program  
-				p.Send(tea.BlurMsg{})
-				return tea.Key{Type: tea.KeyEsc} // Return escape as fallback
-			}
-		}
-		// Try to read one more character
-		moreBuf := make([]byte, 1)
-		if n2, err := os.Stdin.Read(moreBuf); err == nil && n2 > 0 {
-			switch moreBuf[0] {
-			case 'A':
-				return tea.Key{Type: tea.KeyUp}
-			case 'B':
-				return tea.Key{Type: tea.KeyDown}
-			case 'C':
-				return tea.Key{Type: tea.KeyRight}
-			case 'D':
-				return tea.Key{Type: tea.KeyLeft}
-			case 'I':
-				// Focus gained - send FocusMsg directly to program
-				p.Send(tea.FocusMsg{})
-				return tea.Key{Type: tea.KeyEsc} // Return escape as fallback
-			case 'O':
-				// Focus lost - send BlurMsg directly to program
-				p.Send(tea.BlurMsg{})
-				return tea.Key{Type: tea.KeyEsc} // Return escape as fallback
-			}
+	if b != '[' && b != 'O' {
+		// ESC before a key means alt was held
+		if b >= 32 && b <= 126 {
+			return tea.Key{Type: tea.KeyRunes, Runes: []rune{rune(b)}, Alt: true}
 		}
+		return tea.Key{Type: tea.KeyEsc}
+	}
+
+	final, err := in.next(p.escTimeout)
+	if err != nil {
+		return tea.Key{Type: tea.KeyRunes, Runes: []rune{rune(b)}, Alt: true}
+	}
+
+	switch final {
+	case 'A':
+		return tea.Key{Type: tea.KeyUp}
+	case 'B':
+		return tea.Key{Type: tea.KeyDown}
+	case 'C':
+		return tea.Key{Type: tea.KeyRight}
+	case 'D':
+		return tea.Key{Type: tea.KeyLeft}
+	case 'I':
+		// Focus gained - send FocusMsg directly to program
+		p.Send(tea.FocusMsg{})
+		return tea.Key{Type: tea.KeyEsc} // Return escape as fallback
+	case 'O':
+		// Focus lost - send BlurMsg directly to program
+		p.Send(tea.BlurMsg{})
+		return tea.Key{Type: tea.KeyEsc} // Return escape as fallback
 	}
 
 	return tea.Key{Type: tea.KeyEsc}
-}
\ No newline at end of file
+}
@@ -0,0 +1,156 @@
+package brew
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestParseInputIncomplete(t *testing.T) {
+	p := NewProgram(nil)
+	tests := []struct {
+		name  string
+		input string
+		n     int
+		msg   Msg
+	}{
+		{"lone ESC waits", "\x1b", 0, nil},
+		{"CSI waits", "\x1b[1;5", 0, nil},
+		{"SS3 waits", "\x1bO", 0, nil},
+		{"OSC waits", "\x1b]52;c;aGVs", 0, nil},
+		{"DCS waits", "\x1bP>|xterm", 0, nil},
+		{"truncated UTF-8 waits", "\xe6\x97", 0, nil},
+		{"alt+key", "\x1ba", 2, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}, Alt: true}},
+		{"complete CSI", "\x1b[A", 3, tea.KeyMsg{Type: tea.KeyUp}},
+		{"complete SS3", "\x1bOP", 3, tea.KeyMsg{Type: tea.KeyF1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, msg := p.parseInput([]byte(tt.input))
+			if n != tt.n || !reflect.DeepEqual(msg, tt.msg) {
+				t.Errorf("parseInput(%q) = %d, %#v, want %d, %#v", tt.input, n, msg, tt.n, tt.msg)
+			}
+		})
+	}
+}
+
+func TestExpireInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		n     int
+		msg   Msg
+	}{
+		{"lone ESC is Esc", "\x1b", 1, tea.KeyMsg{Type: tea.KeyEsc}},
+		{"ESC ESC is alt+Esc", "\x1b\x1b", 2, tea.KeyMsg{Type: tea.KeyEsc, Alt: true}},
+		{"ESC before truncated UTF-8", "\x1b\xe6", 1, tea.KeyMsg{Type: tea.KeyEsc}},
+		{"truncated UTF-8 is dropped", "\xe6\x97", 1, nil},
+		{"CSI keeps waiting", "\x1b[1;5", 0, nil},
+		{"OSC keeps waiting", "\x1b]52;c;", 0, nil},
+		{"DCS keeps waiting", "\x1bP", 0, nil},
+		{"SS3 keeps waiting", "\x1bO", 0, nil},
+		{"alt CSI keeps waiting", "\x1b\x1b[", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, msg := expireInput([]byte(tt.input))
+			if n != tt.n || !reflect.DeepEqual(msg, tt.msg) {
+				t.Errorf("expireInput(%q) = %d, %#v, want %d, %#v", tt.input, n, msg, tt.n, tt.msg)
+			}
+		})
+	}
+}
+
+func TestReadInputsCompat(t *testing.T) {
+	const timeout = 10 * time.Millisecond
+	tests := []struct {
+		name   string
+		chunks []string
+		want   []Msg
+	}{
+		{
+			name:   "split CSI",
+			chunks: []string{"\x1b[1;", "5A"},
+			want:   []Msg{tea.KeyMsg{Type: tea.KeyCtrlUp}},
+		},
+		{
+			name:   "lone ESC",
+			chunks: []string{"\x1b", "a"},
+			want:   []Msg{tea.KeyMsg{Type: tea.KeyEsc}, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}}},
+		},
+		{
+			name:   "alt+key",
+			chunks: []string{"\x1bx"},
+			want:   []Msg{tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}, Alt: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readChunks(t, timeout, tt.chunks)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("messages = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// readChunks writes each chunk to readInputsCompat, pausing past the escape
+// timeout in between, and returns the messages it sent
+func readChunks(t *testing.T, timeout time.Duration, chunks []string) []Msg {
+	t.Helper()
+	p := NewProgram(nil).WithEscapeTimeout(timeout)
+	r, w := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	msgs := make(chan Msg, 64)
+	done := make(chan error, 1)
+	go func() { done <- p.readInputsCompat(ctx, msgs, r) }()
+
+	for _, chunk := range chunks {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * timeout)
+	}
+	w.Close()
+	if err := <-done; err != io.EOF {
+		t.Fatalf("readInputsCompat = %v, want EOF", err)
+	}
+	close(msgs)
+
+	var got []Msg
+	for msg := range msgs {
+		got = append(got, msg)
+	}
+	return got
+}
+
+func TestReadInputsCompatSplitClipboard(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+	}{
+		{"split in the payload", []string{"\x1b]52;c;aGVs", "bG8=\x07"}},
+		{"split after the introducer", []string{"\x1b]", "52;c;aGVsbG8=\x1b\\"}},
+		{"split inside the terminator", []string{"\x1b]52;c;aGVsbG8=\x1b", "\\"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readChunks(t, 10*time.Millisecond, tt.chunks)
+			for _, msg := range got {
+				if _, ok := msg.(tea.KeyMsg); ok {
+					t.Errorf("reply leaked as key %#v", msg)
+				}
+			}
+			want := []Msg{ClipboardMsg{Content: "hello"}}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("messages = %#v, want %#v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,568 @@
+package brew
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// KeyboardMode selects how keys are encoded by the terminal
+type KeyboardMode int
+
+const (
+	// KeyboardLegacy uses the traditional encoding, where e.g. ctrl+i and tab
+	// are the same key (default)
+	KeyboardLegacy KeyboardMode = iota
+	// KeyboardDisambiguate negotiates the kitty keyboard protocol, or xterm's
+	// modifyOtherKeys as a fallback, so every key combination is reported
+	// distinctly. Keys tea.KeyMsg can express still arrive as tea.KeyMsg, the
+	// others as KeyEventMsg.
+	KeyboardDisambiguate
+	// KeyboardAllEvents additionally asks for repeat and release events, and
+	// for the text each key produces. With the kitty protocol every key then
+	// arrives as a KeyEventMsg.
+	KeyboardAllEvents
+)
+
+// Kitty progressive enhancement flags
+// (https://sw.kovidgoyal.net/kitty/keyboard-protocol/)
+const (
+	kittyDisambiguate   = 1
+	kittyEventTypes     = 2
+	kittyAlternateKeys  = 4
+	kittyAllKeysAsCode  = 8
+	kittyAssociatedText = 16
+)
+
+// keyboardProtocol is the enhanced key encoding negotiated with the terminal
+type keyboardProtocol int
+
+const (
+	keyboardNone keyboardProtocol = iota
+	keyboardNegotiating
+	keyboardKitty
+	keyboardModifyOtherKeys
+)
+
+// keyboardFlagsQuery names the query asking for the kitty keyboard flags
+const keyboardFlagsQuery = "keyboard flags"
+
+// negotiateKeyboard asks the terminal for its kitty keyboard flags, followed
+// by DA1. Only terminals supporting the protocol answer the first query, while
+// all of them answer DA1, so a DA1 reply on its own means there is no support.
+func (p *Program) negotiateKeyboard() {
+	p.keyboardProto = keyboardNegotiating
+	p.keyboardDA1 = true
+	if _, failed := p.handleQuery(queryMsg{name: keyboardFlagsQuery, seq: "\033[?u\033[c"}); failed {
+		p.useKeyboardProtocol(keyboardModifyOtherKeys)
+	}
+}
+
+// handleKeyboardReply completes the negotiation with the replies to its query.
+// It reports whether msg was consumed.
+func (p *Program) handleKeyboardReply(msg Msg) bool {
+	switch m := msg.(type) {
+	case keyboardFlagsMsg:
+		delete(p.queries, keyboardFlagsQuery)
+		if p.keyboardProto == keyboardNegotiating {
+			p.useKeyboardProtocol(keyboardKitty)
+		}
+		return true
+	case DeviceAttributesMsg:
+		if !p.keyboardDA1 {
+			return false
+		}
+		p.keyboardDA1 = false
+		delete(p.queries, keyboardFlagsQuery)
+		if p.keyboardProto == keyboardNegotiating {
+			p.useKeyboardProtocol(keyboardModifyOtherKeys)
+		}
+		// Pass the reply on when the model asked for it too
+		_, requested := p.queries[m.queryName()]
+		return !requested
+	case queryTimeoutFireMsg:
+		if m.name != keyboardFlagsQuery {
+			return false
+		}
+		if p.queries[m.name] == m.generation && p.keyboardProto == keyboardNegotiating {
+			delete(p.queries, m.name)
+			p.useKeyboardProtocol(keyboardModifyOtherKeys)
+		}
+		return true
+	}
+	return false
+}
+
+// useKeyboardProtocol switches the terminal to the negotiated key encoding
+func (p *Program) useKeyboardProtocol(proto keyboardProtocol) {
+	p.keyboardProto = proto
+	switch proto {
+	case keyboardKitty:
+		flags := kittyDisambiguate
+		if p.keyboard == KeyboardAllEvents {
+			flags |= kittyEventTypes | kittyAlternateKeys | kittyAllKeysAsCode | kittyAssociatedText
+		}
+		p.terminal.PushKeyboardFlags(flags)
+
+		// Tell the model the protocol is confirmed
+		p.terminal.caps.KittyKeyboard = true
+		p.pending = append(p.pending, CapabilitiesMsg{Capabilities: p.terminal.Capabilities()})
+	case keyboardModifyOtherKeys:
+		p.terminal.EnableModifyOtherKeys()
+	}
+}
+
+// restoreKeyboard restores the key encoding in use before the program started
+func (p *Program) restoreKeyboard() {
+	switch p.keyboardProto {
+	case keyboardKitty:
+		p.terminal.PopKeyboardFlags()
+	case keyboardModifyOtherKeys:
+		p.terminal.DisableModifyOtherKeys()
+	}
+}
+
+// KeyMod is a set of modifier keys
+type KeyMod int
+
+const (
+	ModShift KeyMod = 1 << iota
+	ModAlt
+	ModCtrl
+	ModSuper
+	ModHyper
+	ModMeta
+	ModCapsLock
+	ModNumLock
+)
+
+// KeyEventType tells whether a key was pressed, repeated or released
+type KeyEventType int
+
+const (
+	KeyEventPress KeyEventType = iota + 1
+	KeyEventRepeat
+	KeyEventRelease
+)
+
+// KeyEventMsg reports a key with the detail of the enhanced keyboard
+// protocols: keys tea.KeyMsg cannot express, such as ctrl+i or shift+enter,
+// and, with KeyboardAllEvents, repeat and release events
+type KeyEventMsg struct {
+	Key     Key          // the key without modifiers, e.g. KeyEnter or the rune 'i'
+	Code    rune         // the key code reported by the terminal
+	Mods    KeyMod       // held modifiers, lock keys excluded
+	Event   KeyEventType // press, repeat or release
+	Shifted rune         // the key with shift applied, e.g. '!' for shift+1, if reported
+	Text    string       // the text the key produces, if reported
+}
+
+// kittyKeys maps the keypad keys and F13-F20, which the kitty protocol
+// reports from the Unicode private use area, to the keys they stand for
+var kittyKeys = map[rune]Key{
+	57364: {Type: tea.KeyF13}, 57365: {Type: tea.KeyF14}, 57366: {Type: tea.KeyF15},
+	57367: {Type: tea.KeyF16}, 57368: {Type: tea.KeyF17}, 57369: {Type: tea.KeyF18},
+	57370: {Type: tea.KeyF19}, 57371: {Type: tea.KeyF20},
+	57399: runeKey('0'), 57400: runeKey('1'), 57401: runeKey('2'), 57402: runeKey('3'),
+	57403: runeKey('4'), 57404: runeKey('5'), 57405: runeKey('6'), 57406: runeKey('7'),
+	57407: runeKey('8'), 57408: runeKey('9'), 57409: runeKey('.'), 57410: runeKey('/'),
+	57411: runeKey('*'), 57412: runeKey('-'), 57413: runeKey('+'), 57414: {Type: tea.KeyEnter},
+	57415: runeKey('='), 57416: runeKey(','), 57417: {Type: tea.KeyLeft}, 57418: {Type: tea.KeyRight},
+	57419: {Type: tea.KeyUp}, 57420: {Type: tea.KeyDown}, 57421: {Type: tea.KeyPgUp},
+	57422: {Type: tea.KeyPgDown}, 57423: {Type: tea.KeyHome}, 57424: {Type: tea.KeyEnd},
+	57425: {Type: tea.KeyInsert}, 57426: {Type: tea.KeyDelete},
+}
+
+// kittyKeyNames names the private use area keys that have no tea.Key. They
+// arrive as a KeyEventMsg with an empty Key.
+var kittyKeyNames = map[rune]string{
+	57358: "caps_lock", 57359: "scroll_lock", 57360: "num_lock",
+	57361: "print_screen", 57362: "pause", 57363: "menu", 57427: "kp_begin",
+	57428: "media_play", 57429: "media_pause", 57430: "media_play_pause",
+	57431: "media_reverse", 57432: "media_stop", 57433: "media_fast_forward",
+	57434: "media_rewind", 57435: "media_track_next", 57436: "media_track_previous",
+	57437: "media_record", 57438: "lower_volume", 57439: "raise_volume", 57440: "mute_volume",
+	57441: "left_shift", 57442: "left_ctrl", 57443: "left_alt", 57444: "left_super",
+	57445: "left_hyper", 57446: "left_meta", 57447: "right_shift", 57448: "right_ctrl",
+	57449: "right_alt", 57450: "right_super", 57451: "right_hyper", 57452: "right_meta",
+	57453: "iso_level3_shift", 57454: "iso_level5_shift",
+}
+
+// runeKey returns the key typing r
+func runeKey(r rune) Key {
+	return Key{Type: tea.KeyRunes, Runes: []rune{r}}
+}
+
+// String returns the key with its modifiers, e.g. "ctrl+shift+enter"
+func (k KeyEventMsg) String() string {
+	var sb strings.Builder
+	for _, mod := range []struct {
+		mod  KeyMod
+		name string
+	}{
+		{ModCtrl, "ctrl"}, {ModAlt, "alt"}, {ModShift, "shift"},
+		{ModSuper, "super"}, {ModHyper, "hyper"}, {ModMeta, "meta"},
+	} {
+		if k.Mods&mod.mod != 0 {
+			sb.WriteString(mod.name + "+")
+		}
+	}
+	if name, ok := kittyKeyNames[k.Code]; ok {
+		sb.WriteString(name)
+	} else {
+		sb.WriteString(k.Key.String())
+	}
+	return sb.String()
+}
+
+// Legacy returns the tea.KeyMsg matching the key, if there is one
+func (k KeyEventMsg) Legacy() (tea.KeyMsg, bool) {
+	if k.Event == KeyEventRelease {
+		return tea.KeyMsg{}, false
+	}
+	base, mods := k.Key, k.Mods
+	if k.Shifted != 0 && mods&ModShift != 0 {
+		base, mods = runeKey(k.Shifted), mods&^ModShift
+	}
+	key, ok := legacyKey(base, mods)
+	return tea.KeyMsg(key), ok
+}
+
+// keyEvent builds the message for a key reported with modifiers
+func (p *Program) keyEvent(base Key, code rune, mods KeyMod, event KeyEventType) Msg {
+	return p.keyMsg(KeyEventMsg{Key: base, Code: code, Mods: mods, Event: event})
+}
+
+// keyMsg returns the message for a key event, preferring tea.KeyMsg unless
+// the key needs the extra detail
+func (p *Program) keyMsg(ev KeyEventMsg) Msg {
+	ev.Mods &^= ModCapsLock | ModNumLock
+	if p.keyboard == KeyboardAllEvents {
+		return ev
+	}
+	if key, ok := ev.Legacy(); ok {
+		return key
+	}
+	return ev
+}
+
+// legacyKey converts a key and its modifiers to the legacy tea.Key, failing
+// when the legacy encoding cannot tell the combination apart
+func legacyKey(base Key, mods KeyMod) (Key, bool) {
+	key := base
+	key.Alt = mods&ModAlt != 0
+	mods &^= ModAlt
+
+	switch base.Type {
+	case tea.KeyRunes:
+		if len(base.Runes) == 0 {
+			// A named key such as a media key
+			return key, false
+		}
+		if len(base.Runes) != 1 {
+			return key, mods == 0
+		}
+		r := base.Runes[0]
+		switch mods {
+		case 0:
+			return key, true
+		case ModShift:
+			if upper := unicode.ToUpper(r); upper != r {
+				key.Runes = []rune{upper}
+				return key, true
+			}
+		case ModCtrl:
+			switch {
+			// These share their code with tab, enter, enter and backspace
+			case r == 'i', r == 'm', r == 'j', r == 'h':
+			case r >= 'a' && r <= 'z':
+				key.Type, key.Runes = tea.KeyType(r-'a'+1), nil
+				return key, true
+			case r == '@':
+				key.Type, key.Runes = tea.KeyCtrlAt, nil
+				return key, true
+			case r == '\\', r == ']', r == '^', r == '_':
+				key.Type, key.Runes = tea.KeyType(r-'@'), nil
+				return key, true
+			}
+		}
+		return key, false
+
+	case tea.KeySpace:
+		switch mods {
+		case 0:
+			return key, true
+		case ModCtrl:
+			key.Type, key.Runes = tea.KeyCtrlAt, nil
+			return key, true
+		}
+		return key, false
+
+	case tea.KeyTab:
+		if mods == ModShift {
+			key.Type = tea.KeyShiftTab
+			return key, true
+		}
+	}
+
+	if mods == 0 {
+		return key, true
+	}
+	if modified, ok := modifiedKeys[base.Type][mods]; ok {
+		key.Type = modified
+		return key, true
+	}
+	return key, false
+}
+
+// modifiedKeys lists the legacy key types of modified navigation keys
+var modifiedKeys = map[tea.KeyType]map[KeyMod]tea.KeyType{
+	tea.KeyUp:     {ModShift: tea.KeyShiftUp, ModCtrl: tea.KeyCtrlUp, ModCtrl | ModShift: tea.KeyCtrlShiftUp},
+	tea.KeyDown:   {ModShift: tea.KeyShiftDown, ModCtrl: tea.KeyCtrlDown, ModCtrl | ModShift: tea.KeyCtrlShiftDown},
+	tea.KeyRight:  {ModShift: tea.KeyShiftRight, ModCtrl: tea.KeyCtrlRight, ModCtrl | ModShift: tea.KeyCtrlShiftRight},
+	tea.KeyLeft:   {ModShift: tea.KeyShiftLeft, ModCtrl: tea.KeyCtrlLeft, ModCtrl | ModShift: tea.KeyCtrlShiftLeft},
+	tea.KeyHome:   {ModShift: tea.KeyShiftHome, ModCtrl: tea.KeyCtrlHome, ModCtrl | ModShift: tea.KeyCtrlShiftHome},
+	tea.KeyEnd:    {ModShift: tea.KeyShiftEnd, ModCtrl: tea.KeyCtrlEnd, ModCtrl | ModShift: tea.KeyCtrlShiftEnd},
+	tea.KeyPgUp:   {ModCtrl: tea.KeyCtrlPgUp},
+	tea.KeyPgDown: {ModCtrl: tea.KeyCtrlPgDown},
+}
+
+// csiFinalKeys maps the final byte of CSI and SS3 key sequences to keys
+var csiFinalKeys = map[byte]tea.KeyType{
+	'A': tea.KeyUp, 'B': tea.KeyDown, 'C': tea.KeyRight, 'D': tea.KeyLeft,
+	'H': tea.KeyHome, 'F': tea.KeyEnd, 'P': tea.KeyF1, 'Q': tea.KeyF2, 'S': tea.KeyF4,
+}
+
+// tildeKeys maps the number of CSI <n> ~ sequences to keys
+var tildeKeys = map[int]tea.KeyType{
+	1: tea.KeyHome, 2: tea.KeyInsert, 3: tea.KeyDelete, 4: tea.KeyEnd,
+	5: tea.KeyPgUp, 6: tea.KeyPgDown, 7: tea.KeyHome, 8: tea.KeyEnd,
+	11: tea.KeyF1, 12: tea.KeyF2, 13: tea.KeyF3, 14: tea.KeyF4, 15: tea.KeyF5,
+	17: tea.KeyF6, 18: tea.KeyF7, 19: tea.KeyF8, 20: tea.KeyF9, 21: tea.KeyF10,
+	23: tea.KeyF11, 24: tea.KeyF12, 25: tea.KeyF13, 26: tea.KeyF14, 28: tea.KeyF15,
+	29: tea.KeyF16, 31: tea.KeyF17, 32: tea.KeyF18, 33: tea.KeyF19, 34: tea.KeyF20,
+}
+
+// maxSequenceLen bounds how long an unterminated escape sequence may grow
+// before it is discarded
+const maxSequenceLen = 256
+
+// parseInput decodes the first message in b and returns it with the number
+// of bytes it used. A zero length means b holds an incomplete sequence; the
+// message may be nil for sequences that are consumed silently.
+func (p *Program) parseInput(b []byte) (int, Msg) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	if b[0] != 0x1b {
+		if b[0] < utf8.RuneSelf {
+			return 1, *p.detectSimpleKey(b[0])
+		}
+		if !utf8.FullRune(b) {
+			return 0, nil
+		}
+		r, n := utf8.DecodeRune(b)
+		if r == utf8.RuneError {
+			return n, nil
+		}
+		return n, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}}
+	}
+
+	if len(b) == 1 {
+		return 0, nil
+	}
+
+	switch b[1] {
+	case '[':
+		return p.parseCSI(b)
+	case ']', 'P':
+		// OSC and DCS only reach the input as replies to queries
+		return parseStringSequence(b)
+	case 'O':
+		// SS3 encodes F1-F4 and the cursor keys in application mode
+		if len(b) < 3 {
+			return 0, nil
+		}
+		if key, ok := csiFinalKeys[b[2]]; ok {
+			return 3, tea.KeyMsg{Type: key}
+		}
+		if b[2] == 'R' {
+			return 3, tea.KeyMsg{Type: tea.KeyF3}
+		}
+		return 3, nil
+	}
+
+	// ESC before a key means alt was held
+	n, msg := p.parseInput(b[1:])
+	if n == 0 {
+		return 0, nil
+	}
+	if key, ok := msg.(tea.KeyMsg); ok {
+		key.Alt = true
+		return n + 1, key
+	}
+	return n + 1, msg
+}
+
+// parseCSI decodes a control sequence starting at b, which begins with ESC [
+func (p *Program) parseCSI(b []byte) (int, Msg) {
+	end := 2
+	for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+		end++
+	}
+	if end == len(b) {
+		if len(b) >= maxSequenceLen {
+			return len(b), nil
+		}
+		return 0, nil
+	}
+
+	n := end + 1
+	params := string(b[2:end])
+	final := b[end]
+
+	switch final {
+	case 'I', 'O':
+		if params == "" {
+			if final == 'I' {
+				return n, tea.FocusMsg{}
+			}
+			return n, tea.BlurMsg{}
+		}
+	case 'Z':
+		return n, tea.KeyMsg{Type: tea.KeyShiftTab}
+	case 'R':
+		// A cursor position report, the reply to DSR 6, shares its encoding
+		// with F3 and modifiers, so it is only taken as one while a query is
+		// unanswered
+		fields := strings.Split(params, ";")
+		if len(fields) == 2 {
+			row, errRow := strconv.Atoi(fields[0])
+			col, errCol := strconv.Atoi(fields[1])
+			if errRow == nil && errCol == nil && p.takeCursorQuery() {
+				return n, cursorPositionMsg{row: row, col: col}
+			}
+		}
+		mods, event := keyModifiers(fields, 1)
+		return n, p.keyEvent(Key{Type: tea.KeyF3}, 0, mods, event)
+	case 'c', 'y':
+		return n, parseReplyCSI(params, final)
+	case 'u':
+		if strings.HasPrefix(params, "?") {
+			return n, parseReplyCSI(params, final)
+		}
+		return n, p.parseKittyKey(params)
+	case '~':
+		fields := strings.Split(params, ";")
+		number, _ := strconv.Atoi(fields[0])
+		mods, event := keyModifiers(fields, 1)
+
+		// modifyOtherKeys reports CSI 27 ; <mods> ; <code> ~
+		if number == 27 && len(fields) == 3 {
+			code, _ := strconv.Atoi(fields[2])
+			return n, p.keyEvent(codeKey(rune(code)), rune(code), mods, event)
+		}
+		if key, ok := tildeKeys[number]; ok {
+			return n, p.keyEvent(Key{Type: key}, 0, mods, event)
+		}
+	}
+
+	if key, ok := csiFinalKeys[final]; ok {
+		mods, event := keyModifiers(strings.Split(params, ";"), 1)
+		return n, p.keyEvent(Key{Type: key}, 0, mods, event)
+	}
+
+	// Unknown sequences are dropped instead of surfacing as garbage keys
+	return n, nil
+}
+
+// parseKittyKey decodes the parameters of a kitty
+// CSI <code>:<shifted>:<base> ; <mods>:<event> ; <text> u key
+func (p *Program) parseKittyKey(params string) Msg {
+	fields := strings.Split(params, ";")
+	codes := strings.Split(fields[0], ":")
+	code, err := strconv.Atoi(codes[0])
+	if err != nil {
+		return nil
+	}
+	key, ok := kittyKey(rune(code))
+	if !ok {
+		return nil
+	}
+	ev := KeyEventMsg{Key: key, Code: rune(code)}
+	ev.Mods, ev.Event = keyModifiers(fields, 1)
+
+	// Alternate keys, with flag 4
+	if len(codes) > 1 && codes[1] != "" {
+		if shifted, err := strconv.Atoi(codes[1]); err == nil && utf8.ValidRune(rune(shifted)) {
+			ev.Shifted = rune(shifted)
+		}
+	}
+	// Text as colon separated code points, with flag 16
+	if len(fields) > 2 {
+		var text strings.Builder
+		for _, cp := range strings.Split(fields[2], ":") {
+			if r, err := strconv.Atoi(cp); err == nil && utf8.ValidRune(rune(r)) {
+				text.WriteRune(rune(r))
+			}
+		}
+		ev.Text = text.String()
+	}
+	return p.keyMsg(ev)
+}
+
+// kittyKey returns the key for a kitty key code. Codes from the private use
+// area are looked up, and those naming no known key are not reported.
+func kittyKey(code rune) (Key, bool) {
+	if code < 57344 || code > 63743 {
+		return codeKey(code), true
+	}
+	if key, ok := kittyKeys[code]; ok {
+		return key, true
+	}
+	if _, ok := kittyKeyNames[code]; ok {
+		return Key{Type: tea.KeyRunes}, true
+	}
+	return Key{}, false
+}
+
+// keyModifiers parses the "<mods>:<event>" field at index i, where mods is
+// one more than the modifier bit set
+func keyModifiers(fields []string, i int) (KeyMod, KeyEventType) {
+	if i >= len(fields) {
+		return 0, KeyEventPress
+	}
+	parts := strings.Split(fields[i], ":")
+	mods, err := strconv.Atoi(parts[0])
+	if err != nil || mods < 1 {
+		mods = 1
+	}
+	event := KeyEventPress
+	if len(parts) > 1 {
+		if e, err := strconv.Atoi(parts[1]); err == nil && e >= 1 && e <= 3 {
+			event = KeyEventType(e)
+		}
+	}
+	return KeyMod(mods - 1), event
+}
+
+// codeKey returns the unmodified key for a Unicode key code
+func codeKey(code rune) Key {
+	switch code {
+	case 13:
+		return Key{Type: tea.KeyEnter}
+	case 9:
+		return Key{Type: tea.KeyTab}
+	case 27:
+		return Key{Type: tea.KeyEsc}
+	case 127, 8:
+		return Key{Type: tea.KeyBackspace}
+	case 32:
+		return Key{Type: tea.KeySpace, Runes: []rune{' '}}
+	}
+	return Key{Type: tea.KeyRunes, Runes: []rune{code}}
+}
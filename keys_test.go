@@ -0,0 +1,187 @@
+package brew
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		keyboard KeyboardMode
+		input    string
+		want     Msg
+	}{
+		// Legacy encoding
+		{"rune", KeyboardLegacy, "a", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}}},
+		{"utf-8 rune", KeyboardLegacy, "é", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'é'}}},
+		{"ctrl+c", KeyboardLegacy, "\x03", tea.KeyMsg{Type: tea.KeyCtrlC}},
+		{"enter", KeyboardLegacy, "\r", tea.KeyMsg{Type: tea.KeyEnter}},
+		{"tab", KeyboardLegacy, "\t", tea.KeyMsg{Type: tea.KeyTab}},
+		{"backspace", KeyboardLegacy, "\x7f", tea.KeyMsg{Type: tea.KeyBackspace}},
+		{"alt+rune", KeyboardLegacy, "\x1bb", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'b'}, Alt: true}},
+		{"up", KeyboardLegacy, "\x1b[A", tea.KeyMsg{Type: tea.KeyUp}},
+		{"application up", KeyboardLegacy, "\x1bOA", tea.KeyMsg{Type: tea.KeyUp}},
+		{"ctrl+right", KeyboardLegacy, "\x1b[1;5C", tea.KeyMsg{Type: tea.KeyCtrlRight}},
+		{"shift+tab", KeyboardLegacy, "\x1b[Z", tea.KeyMsg{Type: tea.KeyShiftTab}},
+		{"delete", KeyboardLegacy, "\x1b[3~", tea.KeyMsg{Type: tea.KeyDelete}},
+		{"F5", KeyboardLegacy, "\x1b[15~", tea.KeyMsg{Type: tea.KeyF5}},
+		{"SS3 F3", KeyboardLegacy, "\x1bOR", tea.KeyMsg{Type: tea.KeyF3}},
+		{"focus", KeyboardLegacy, "\x1b[I", tea.FocusMsg{}},
+		{"unknown sequence", KeyboardLegacy, "\x1b[99X", nil},
+
+		// CSI u
+		{"CSI u rune", KeyboardDisambiguate, "\x1b[97u", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}}},
+		{"CSI u ctrl+a", KeyboardDisambiguate, "\x1b[97;5u", tea.KeyMsg{Type: tea.KeyCtrlA}},
+		{"CSI u ctrl+i", KeyboardDisambiguate, "\x1b[105;5u", KeyEventMsg{Key: runeKey('i'), Code: 'i', Mods: ModCtrl, Event: KeyEventPress}},
+		{"CSI u shift+enter", KeyboardDisambiguate, "\x1b[13;2u", KeyEventMsg{Key: Key{Type: tea.KeyEnter}, Code: 13, Mods: ModShift, Event: KeyEventPress}},
+		{"CSI u alt+shift+a", KeyboardDisambiguate, "\x1b[97;4u", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'A'}, Alt: true}},
+		{"CSI u esc", KeyboardDisambiguate, "\x1b[27u", tea.KeyMsg{Type: tea.KeyEsc}},
+		{"CSI u lock keys are ignored", KeyboardDisambiguate, "\x1b[97;65u", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}}},
+		{"keypad 0", KeyboardDisambiguate, "\x1b[57399u", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'0'}}},
+		{"keypad enter", KeyboardDisambiguate, "\x1b[57414u", tea.KeyMsg{Type: tea.KeyEnter}},
+		{"keypad left", KeyboardDisambiguate, "\x1b[57417;5u", tea.KeyMsg{Type: tea.KeyCtrlLeft}},
+		{"F13", KeyboardDisambiguate, "\x1b[57364u", tea.KeyMsg{Type: tea.KeyF13}},
+		{"media key", KeyboardDisambiguate, "\x1b[57430u", KeyEventMsg{Key: Key{Type: tea.KeyRunes}, Code: 57430, Event: KeyEventPress}},
+		{"unknown private use key", KeyboardDisambiguate, "\x1b[57500u", nil},
+		{"shifted alternate key", KeyboardDisambiguate, "\x1b[49:33;2u", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'!'}}},
+
+		// modifyOtherKeys
+		{"modifyOtherKeys ctrl+i", KeyboardDisambiguate, "\x1b[27;5;105~", KeyEventMsg{Key: runeKey('i'), Code: 'i', Mods: ModCtrl, Event: KeyEventPress}},
+		{"modifyOtherKeys ctrl+shift+a", KeyboardDisambiguate, "\x1b[27;6;65~", KeyEventMsg{Key: runeKey('A'), Code: 'A', Mods: ModCtrl | ModShift, Event: KeyEventPress}},
+		{"modifyOtherKeys shift+enter", KeyboardDisambiguate, "\x1b[27;2;13~", KeyEventMsg{Key: Key{Type: tea.KeyEnter}, Code: 13, Mods: ModShift, Event: KeyEventPress}},
+
+		// Event types
+		{"press", KeyboardAllEvents, "\x1b[97;1:1u", KeyEventMsg{Key: runeKey('a'), Code: 'a', Event: KeyEventPress}},
+		{"repeat", KeyboardAllEvents, "\x1b[97;1:2u", KeyEventMsg{Key: runeKey('a'), Code: 'a', Event: KeyEventRepeat}},
+		{"release", KeyboardAllEvents, "\x1b[97;1:3u", KeyEventMsg{Key: runeKey('a'), Code: 'a', Event: KeyEventRelease}},
+		{"release of a legacy key", KeyboardDisambiguate, "\x1b[97;1:3u", KeyEventMsg{Key: runeKey('a'), Code: 'a', Event: KeyEventRelease}},
+		{"release of an arrow", KeyboardAllEvents, "\x1b[1;1:3A", KeyEventMsg{Key: Key{Type: tea.KeyUp}, Event: KeyEventRelease}},
+		{"modifier key", KeyboardAllEvents, "\x1b[57441;2u", KeyEventMsg{Key: Key{Type: tea.KeyRunes}, Code: 57441, Mods: ModShift, Event: KeyEventPress}},
+		{"text", KeyboardAllEvents, "\x1b[49:33;2;33u", KeyEventMsg{Key: runeKey('1'), Code: '1', Mods: ModShift, Event: KeyEventPress, Shifted: '!', Text: "!"}},
+		{"text of several code points", KeyboardAllEvents, "\x1b[101;1;101:769u", KeyEventMsg{Key: runeKey('e'), Code: 'e', Event: KeyEventPress, Text: "e\u0301"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProgram(nil).WithKeyboardMode(tt.keyboard)
+			n, msg := p.parseInput([]byte(tt.input))
+			if n != len(tt.input) || !reflect.DeepEqual(msg, tt.want) {
+				t.Errorf("parseInput(%q) = %d, %#v, want %d, %#v", tt.input, n, msg, len(tt.input), tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyEventString(t *testing.T) {
+	tests := []struct {
+		ev   KeyEventMsg
+		want string
+	}{
+		{KeyEventMsg{Key: runeKey('i'), Code: 'i', Mods: ModCtrl}, "ctrl+i"},
+		{KeyEventMsg{Key: Key{Type: tea.KeyEnter}, Code: 13, Mods: ModCtrl | ModShift}, "ctrl+shift+enter"},
+		{KeyEventMsg{Key: Key{Type: tea.KeyRunes}, Code: 57441}, "left_shift"},
+		{KeyEventMsg{Key: Key{Type: tea.KeyRunes}, Code: 57430, Mods: ModAlt}, "alt+media_play_pause"},
+	}
+	for _, tt := range tests {
+		if got := tt.ev.String(); got != tt.want {
+			t.Errorf("%#v.String() = %q, want %q", tt.ev, got, tt.want)
+		}
+	}
+}
+
+func TestCursorPositionReport(t *testing.T) {
+	tests := []struct {
+		name    string
+		pending int32
+		input   string
+		want    Msg
+	}{
+		{"reply to a query", 1, "\x1b[12;40R", cursorPositionMsg{row: 12, col: 40}},
+		{"ctrl+F3 while a query is unanswered", 1, "\x1b[1;5R", cursorPositionMsg{row: 1, col: 5}},
+		{"ctrl+F3 without a query", 0, "\x1b[1;5R", KeyEventMsg{Key: Key{Type: tea.KeyF3}, Mods: ModCtrl, Event: KeyEventPress}},
+		{"F3 without modifiers", 1, "\x1b[R", tea.KeyMsg{Type: tea.KeyF3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProgram(nil)
+			p.cprPending.Store(tt.pending)
+			n, msg := p.parseInput([]byte(tt.input))
+			if n != len(tt.input) || !reflect.DeepEqual(msg, tt.want) {
+				t.Errorf("parseInput(%q) = %d, %#v, want %d, %#v", tt.input, n, msg, len(tt.input), tt.want)
+			}
+		})
+	}
+
+	p := NewProgram(nil)
+	p.cprPending.Store(1)
+	p.parseInput([]byte("\x1b[1;5R"))
+	if _, msg := p.parseInput([]byte("\x1b[1;5R")); !reflect.DeepEqual(msg, KeyEventMsg{Key: Key{Type: tea.KeyF3}, Mods: ModCtrl, Event: KeyEventPress}) {
+		t.Errorf("second report for one query = %#v, want a key", msg)
+	}
+}
+
+func TestCursorQueryTimeout(t *testing.T) {
+	var out bytes.Buffer
+	p := NewProgram(nil)
+	p.terminal = NewTerminal().WithOutput(&out).WithOutputMode(OutputInteractive)
+	defer p.cancel()
+
+	p.queryCursorPosition()
+	p.queryCursorPosition()
+	if got := p.cprPending.Load(); got != 2 {
+		t.Fatalf("pending queries = %d, want 2", got)
+	}
+
+	p.dispatch(queryTimeoutFireMsg{name: cursorPositionQuery, generation: 1})
+	if got := p.cprPending.Load(); got != 2 {
+		t.Fatalf("pending queries after a superseded timeout = %d, want 2", got)
+	}
+	p.dispatch(queryTimeoutFireMsg{name: cursorPositionQuery, generation: 2})
+	if got := p.cprPending.Load(); got != 0 {
+		t.Errorf("pending queries after the timeout = %d, want 0", got)
+	}
+	if len(p.terminal.cprPending) != 0 {
+		t.Errorf("terminal still waits for %d replies", len(p.terminal.cprPending))
+	}
+	if _, ok := p.terminal.InlineHeight(); ok {
+		t.Error("frame located without a reply")
+	}
+}
+
+func TestKeyboardNegotiation(t *testing.T) {
+	tests := []struct {
+		name     string
+		keyboard KeyboardMode
+		replies  []Msg
+		want     string
+	}{
+		{"kitty", KeyboardDisambiguate, []Msg{keyboardFlagsMsg{}, DeviceAttributesMsg{}}, "\x1b[>1u"},
+		{"kitty with all events", KeyboardAllEvents, []Msg{keyboardFlagsMsg{}, DeviceAttributesMsg{}}, "\x1b[>31u"},
+		{"no kitty support", KeyboardDisambiguate, []Msg{DeviceAttributesMsg{}}, "\x1b[>4;2m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			p := NewProgram(nil).WithKeyboardMode(tt.keyboard)
+			p.terminal = NewTerminal().WithOutput(&out).WithOutputMode(OutputInteractive)
+			defer p.cancel()
+
+			p.negotiateKeyboard()
+			if got, want := out.String(), "\x1b[?u\x1b[c"; got != want {
+				t.Fatalf("query = %q, want %q", got, want)
+			}
+			out.Reset()
+			for _, reply := range tt.replies {
+				if updated, _ := p.dispatch(reply); updated {
+					t.Errorf("%T reached the model", reply)
+				}
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package brew
+
+import "strings"
+
+// Node is an element of a layout tree
+type Node interface {
+	// GetStyle returns the border, padding and margin of the node
+	GetStyle() Style
+	// GetMinSize returns the smallest size the node can be drawn at,
+	// including padding and border but not margin
+	GetMinSize() Size
+	// Draw paints the node over the whole of buf, which excludes its margin
+	Draw(buf *RenderBuffer)
+}
+
+// Flex lays out its children along a row or column, like CSS flexbox
+type Flex struct {
+	Direction Direction
+	Justify   Justify // placement along the direction
+	Align     Align   // placement across the direction
+	Gap       int     // cells between children
+	Style     Style
+	Children  []Node
+}
+
+// NewRow creates a container placing children side by side
+func NewRow(children ...Node) *Flex {
+	return &Flex{Direction: Row, Children: children}
+}
+
+// NewColumn creates a container stacking children top to bottom
+func NewColumn(children ...Node) *Flex {
+	return &Flex{Direction: Column, Children: children}
+}
+
+// Layout renders node for use as a View, at size or, for a zero width or
+// height, at the node's own size. The node's margin is included.
+func Layout(node Node, size Size) string {
+	style := node.GetStyle()
+	natural := withMargin(node.GetMinSize(), style.Margin)
+	if size.Width <= 0 {
+		size.Width = natural.Width
+	}
+	if size.Height <= 0 {
+		size.Height = natural.Height
+	}
+	buf := NewRenderBuffer(size.Width, size.Height)
+	drawWithMargin(node, buf)
+	return buf.String()
+}
+
+func (f *Flex) GetStyle() Style {
+	return f.Style
+}
+
+func (f *Flex) GetMinSize() Size {
+	var content Size
+	for i, child := range f.Children {
+		s := withMargin(child.GetMinSize(), child.GetStyle().Margin)
+		main, cross := f.axes(s)
+		if i > 0 {
+			main += f.Gap
+		}
+		if f.Direction == Row {
+			content.Width += main
+			content.Height = max(content.Height, cross)
+		} else {
+			content.Height += main
+			content.Width = max(content.Width, cross)
+		}
+	}
+	return boxSize(f.Style, content)
+}
+
+func (f *Flex) Draw(buf *RenderBuffer) {
+	f.drawContent(drawBox(f.Style, buf))
+}
+
+// axes splits a size into its extent along and across the direction
+func (f *Flex) axes(s Size) (main, cross int) {
+	if f.Direction == Row {
+		return s.Width, s.Height
+	}
+	return s.Height, s.Width
+}
+
+// drawContent places the children inside the content area
+func (f *Flex) drawContent(buf *RenderBuffer) {
+	n := len(f.Children)
+	if n == 0 {
+		return
+	}
+	innerMain, innerCross := f.axes(buf.Size())
+
+	// Children keep their natural size along the direction, shrinking from
+	// the end when they do not fit
+	mains := make([]int, n)
+	used := f.Gap * (n - 1)
+	for i, child := range f.Children {
+		mains[i], _ = f.axes(withMargin(child.GetMinSize(), child.GetStyle().Margin))
+		used += mains[i]
+	}
+	for i := n - 1; i >= 0 && used > innerMain; i-- {
+		cut := min(mains[i], used-innerMain)
+		mains[i] -= cut
+		used -= cut
+	}
+
+	// Distribute the free space according to Justify
+	free := max(innerMain-used, 0)
+	offset := 0
+	spacing := make([]int, n) // extra space after each child
+	switch f.Justify {
+	case JustifyCenter:
+		offset = free / 2
+	case JustifyEnd:
+		offset = free
+	case JustifySpaceBetween:
+		for i := 0; i < n-1; i++ {
+			spacing[i] = free / (n - 1)
+			if i < free%(n-1) {
+				spacing[i]++
+			}
+		}
+	case JustifySpaceAround:
+		around := free / n
+		offset = around / 2
+		for i := 0; i < n-1; i++ {
+			spacing[i] = around
+		}
+	}
+
+	// Draw each child in its slot, aligned across the direction
+	pos := offset
+	for i, child := range f.Children {
+		_, cross := f.axes(withMargin(child.GetMinSize(), child.GetStyle().Margin))
+		cross = min(cross, innerCross)
+		crossOffset := 0
+		switch f.Align {
+		case AlignCenter:
+			crossOffset = (innerCross - cross) / 2
+		case AlignEnd:
+			crossOffset = innerCross - cross
+		case AlignStretch:
+			cross = innerCross
+		}
+
+		if f.Direction == Row {
+			drawWithMargin(child, buf.Region(pos, crossOffset, mains[i], cross))
+		} else {
+			drawWithMargin(child, buf.Region(crossOffset, pos, cross, mains[i]))
+		}
+		pos += mains[i] + f.Gap + spacing[i]
+	}
+}
+
+// withMargin grows a size by a margin
+func withMargin(s Size, margin Box) Size {
+	return Size{
+		Width:  s.Width + margin.Left + margin.Right,
+		Height: s.Height + margin.Top + margin.Bottom,
+	}
+}
+
+// drawWithMargin draws node into buf, which includes its margin
+func drawWithMargin(node Node, buf *RenderBuffer) {
+	m := node.GetStyle().Margin
+	size := buf.Size()
+	node.Draw(buf.Region(m.Left, m.Top, size.Width-m.Left-m.Right, size.Height-m.Top-m.Bottom))
+}
+
+// boxSize grows a content size by the padding and border of style
+func boxSize(style Style, content Size) Size {
+	width := content.Width + style.Padding.Left + style.Padding.Right
+	height := content.Height + style.Padding.Top + style.Padding.Bottom
+	if style.Border {
+		width += 2
+		height += 2
+	}
+	return Size{Width: width, Height: height}
+}
+
+// drawBox fills buf with the background of style and draws its border,
+// returning the region left inside the border and padding
+func drawBox(style Style, buf *RenderBuffer) *RenderBuffer {
+	size := buf.Size()
+	if size.Width == 0 || size.Height == 0 {
+		return buf
+	}
+
+	bg := style.BgChar
+	if bg == 0 {
+		bg = ' '
+	}
+	buf.Fill(Cell{Rune: bg, Fg: style.Foreground, Bg: style.Background})
+
+	border := 0
+	if style.Border && size.Width >= 2 && size.Height >= 2 {
+		border = 1
+		drawBorder(style, buf)
+	}
+	p := style.Padding
+	return buf.Region(
+		border+p.Left,
+		border+p.Top,
+		size.Width-2*border-p.Left-p.Right,
+		size.Height-2*border-p.Top-p.Bottom,
+	)
+}
+
+// drawBorder draws the border of style around the edge of buf
+func drawBorder(style Style, buf *RenderBuffer) {
+	size := buf.Size()
+	vertical := string(style.BorderChar)
+	if style.BorderChar == 0 {
+		vertical = "│"
+	}
+	corners := []rune("┌┐└┘")
+	if style.RoundedBorder {
+		corners = []rune("╭╮╰╯")
+	}
+
+	pen := Cell{Fg: style.BorderColor, Bg: style.Background}
+	horizontal := strings.Repeat("─", size.Width-2)
+	buf.DrawString(0, 0, string(corners[0])+horizontal+string(corners[1]), pen)
+	for y := 1; y < size.Height-1; y++ {
+		buf.DrawString(0, y, vertical, pen)
+		buf.DrawString(size.Width-1, y, vertical, pen)
+	}
+	buf.DrawString(0, size.Height-1, string(corners[2])+horizontal+string(corners[3]), pen)
+}
@@ -0,0 +1,113 @@
+package brew
+
+import "testing"
+
+// marginText creates a text node with a margin
+func marginText(content string, margin Box) *Text {
+	text := NewText(content)
+	text.Style.Margin = margin
+	return text
+}
+
+// borderText creates a text node with a border
+func borderText(content string) *Text {
+	text := NewText(content)
+	text.Style.Border = true
+	return text
+}
+
+func TestFlexJustify(t *testing.T) {
+	tests := []struct {
+		name    string
+		justify Justify
+		gap     int
+		want    string
+	}{
+		{"start", JustifyStart, 0, "abb       "},
+		{"center", JustifyCenter, 0, "   abb    "},
+		{"end", JustifyEnd, 0, "       abb"},
+		{"space between", JustifySpaceBetween, 0, "a       bb"},
+		{"space around", JustifySpaceAround, 0, " a   bb   "},
+		{"gap", JustifyStart, 2, "a  bb     "},
+		{"gap and end", JustifyEnd, 2, "     a  bb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := NewRow(NewText("a"), NewText("bb"))
+			row.Justify, row.Gap = tt.justify, tt.gap
+			if got := Layout(row, Size{Width: 10, Height: 1}); got != tt.want {
+				t.Errorf("Layout = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlexAlign(t *testing.T) {
+	tests := []struct {
+		name string
+		node *Flex
+		want string
+	}{
+		{"column start", &Flex{Direction: Column, Children: []Node{NewText("a"), NewText("bbb")}}, "a  \nbbb"},
+		{"column center", &Flex{Direction: Column, Align: AlignCenter, Children: []Node{NewText("a"), NewText("bbb")}}, " a \nbbb"},
+		{"column end", &Flex{Direction: Column, Align: AlignEnd, Children: []Node{NewText("a"), NewText("bbb")}}, "  a\nbbb"},
+		{"column stretch", &Flex{Direction: Column, Align: AlignStretch, Children: []Node{borderText("a"), NewText("bbbbb")}}, "┌───┐\n│a  │\n└───┘\nbbbbb"},
+		{"row center", &Flex{Direction: Row, Align: AlignCenter, Children: []Node{NewText("a\nb\nc"), NewText("x")}}, "a \nbx\nc "},
+		{"row end", &Flex{Direction: Row, Align: AlignEnd, Children: []Node{NewText("a\nb\nc"), NewText("x")}}, "a \nb \ncx"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Layout(tt.node, Size{}); got != tt.want {
+				t.Errorf("Layout = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayout(t *testing.T) {
+	tests := []struct {
+		name string
+		node Node
+		size Size
+		want string
+	}{
+		{"natural size", NewText("hi\nthere"), Size{}, "hi   \nthere"},
+		{"given size", NewText("hi"), Size{Width: 4, Height: 2}, "hi  \n    "},
+		{"margin", marginText("a", Box{Top: 1, Left: 2}), Size{}, "   \n  a"},
+		{"margin between children", NewRow(marginText("a", Box{Right: 1}), NewText("b")), Size{}, "a b"},
+		{"margin across the row", NewRow(marginText("a", Box{Top: 1}), NewText("b")), Size{}, " b\na "},
+		{"border and padding", &Flex{Style: Style{Border: true, Padding: Box{Left: 1}}, Children: []Node{NewText("ab")}}, Size{}, "┌───┐\n│ ab│\n└───┘"},
+		{"children shrink from the end", NewRow(NewText("abc"), NewText("de")), Size{Width: 4, Height: 1}, "abcd"},
+		{"children shrink away", NewRow(NewText("abc"), NewText("de")), Size{Width: 2, Height: 1}, "ab"},
+		{"empty container", NewColumn(), Size{Width: 2, Height: 1}, "  "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Layout(tt.node, tt.size); got != tt.want {
+				t.Errorf("Layout = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlexMinSize(t *testing.T) {
+	tests := []struct {
+		name string
+		node Node
+		want Size
+	}{
+		{"row", NewRow(NewText("ab"), NewText("c\nd")), Size{Width: 3, Height: 2}},
+		{"column", NewColumn(NewText("ab"), NewText("c\nd")), Size{Width: 2, Height: 3}},
+		{"gap", &Flex{Direction: Row, Gap: 2, Children: []Node{NewText("a"), NewText("b"), NewText("c")}}, Size{Width: 7, Height: 1}},
+		{"child margins", NewColumn(marginText("a", Box{Top: 1, Bottom: 2, Left: 3})), Size{Width: 4, Height: 4}},
+		{"own margin excluded", marginText("a", Box{Top: 1, Left: 1}), Size{Width: 1, Height: 1}},
+		{"border and padding", &Flex{Style: Style{Border: true, Padding: Box{Top: 1, Right: 1}}, Children: []Node{NewText("a")}}, Size{Width: 4, Height: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.node.GetMinSize(); got != tt.want {
+				t.Errorf("GetMinSize() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
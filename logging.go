@@ -15,6 +15,7 @@ func InitLogging(prefex string) {
 		panic(err)
 	}
 	slog.SetDefault(slog.New(slog.NewTextHandler(logFile, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
 		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
 			if a.Key == "time" {
 				return slog.Attr{}
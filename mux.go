@@ -0,0 +1,301 @@
+package brew
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Mux runs several programs stacked vertically in one terminal, like the
+// progress output of docker compose. The mux owns the terminal and the input:
+// each child program renders into its own region, as tall as its view, keys go
+// to the focused child and regions are re-rendered as their models change.
+// ctrl+c quits every child.
+type Mux struct {
+	host      *Program
+	children  []*muxChild
+	focusKey  string
+	maxHeight int
+}
+
+// muxChild is a program running inside a Mux
+type muxChild struct {
+	program *Program
+	inbox   *overflowQueue // messages from the host, so it never blocks on the child
+
+	mu   sync.Mutex
+	size Size
+}
+
+// NewMux creates a multiplexer for the given programs, stacked in order. The
+// terminal related options of the child programs are ignored; configure the
+// terminal through Host instead.
+func NewMux(programs ...*Program) *Mux {
+	m := &Mux{focusKey: "ctrl+n"}
+	for _, p := range programs {
+		m.children = append(m.children, &muxChild{
+			program: p,
+			inbox:   newOverflowQueue(defaultMessageBuffer, p.counters),
+		})
+	}
+	m.host = NewProgram(&muxModel{
+		mux:   m,
+		views: make([]string, len(programs)),
+		done:  make([]bool, len(programs)),
+	})
+	return m
+}
+
+// WithFocusKey sets the key that moves focus to the next child (default
+// "ctrl+n")
+func (m *Mux) WithFocusKey(key string) *Mux {
+	m.focusKey = key
+	return m
+}
+
+// WithMaxHeight limits every region to lines, clipping longer views. Children
+// are told the limit as their window height. Zero, the default, lets regions
+// grow with their views.
+func (m *Mux) WithMaxHeight(lines int) *Mux {
+	m.maxHeight = max(lines, 0)
+	return m
+}
+
+// Host returns the program that owns the terminal, for options such as
+// WithRawMode or WithRecording
+func (m *Mux) Host() *Program {
+	return m.host
+}
+
+// Focus moves keyboard focus to the child at index
+func (m *Mux) Focus(index int) {
+	m.host.Send(muxFocusMsg{index: index})
+}
+
+// Run starts every child and blocks until all of them have quit, or the mux
+// itself is interrupted. It returns the final model of each child.
+func (m *Mux) Run() ([]tea.Model, error) {
+	if len(m.children) == 0 {
+		return nil, errors.New("brew: mux has no programs")
+	}
+
+	models := make([]tea.Model, len(m.children))
+	caps := m.host.terminal.Capabilities()
+	var wg sync.WaitGroup
+	for i, child := range m.children {
+		child.program.terminal.WithSizeFunc(child.regionSize)
+
+		go child.forward()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			models[i], _ = child.program.runChild(caps, func(view string) {
+				m.host.Send(muxViewMsg{index: i, view: view})
+			}, m.host.Send)
+			m.host.Send(muxDoneMsg{index: i})
+		}()
+	}
+
+	_, err := m.host.Run()
+
+	// Stop children that are still running when the host was interrupted
+	for _, child := range m.children {
+		child.program.Kill()
+	}
+	wg.Wait()
+	return models, err
+}
+
+// runChild runs the program's message loop while another program owns the
+// terminal, handing every rendered view to render. Printed lines and escape
+// sequences such as clipboard updates go to the owner through send, so they
+// reach its output and recording.
+func (p *Program) runChild(caps Capabilities, render func(view string), send func(Msg)) (tea.Model, error) {
+	defer close(p.finished)
+	defer p.drainCmds()
+
+	p.viewFunc = render
+	p.hostSend = send
+	p.pending = append(p.pending, CapabilitiesMsg{Capabilities: caps})
+	return p.run()
+}
+
+// regionSize returns the size allocated to the child
+func (c *muxChild) regionSize() (Size, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size.Width == 0 {
+		return c.size, errors.New("brew: region not allocated yet")
+	}
+	return c.size, nil
+}
+
+// resize allocates a new region size to the child and tells its model
+func (c *muxChild) resize(size Size) {
+	c.mu.Lock()
+	c.size = size
+	c.mu.Unlock()
+
+	c.send(tea.WindowSizeMsg{Width: size.Width, Height: size.Height})
+}
+
+// send queues msg for the child without blocking the host, dropping the
+// oldest queued message when the child has fallen far behind
+func (c *muxChild) send(msg Msg) {
+	c.inbox.push(msg, OverflowDropOldest)
+}
+
+// forward delivers the queued messages to the child until it exits
+func (c *muxChild) forward() {
+	for {
+		select {
+		case <-c.inbox.ready:
+			for msg, ok := c.inbox.pop(); ok; msg, ok = c.inbox.pop() {
+				c.program.Send(msg)
+			}
+		case <-c.program.ctx.Done():
+			return
+		}
+	}
+}
+
+// muxViewMsg carries a newly rendered view of a child
+type muxViewMsg struct {
+	index int
+	view  string
+}
+
+// Coalesce keeps only the latest view when a child renders faster than the
+// mux can draw
+func (m muxViewMsg) Coalesce(next Msg) (Msg, bool) {
+	if n, ok := next.(muxViewMsg); ok && n.index == m.index {
+		return n, true
+	}
+	return m, false
+}
+
+// muxDoneMsg signals that a child program has quit
+type muxDoneMsg struct {
+	index int
+}
+
+// muxFocusMsg moves focus to a child
+type muxFocusMsg struct {
+	index int
+}
+
+// muxModel is the model of the host program, composing the child regions
+type muxModel struct {
+	mux   *Mux
+	views []string
+	done  []bool
+	focus int
+}
+
+func (m *muxModel) Init() tea.Cmd {
+	m.mux.children[0].send(tea.FocusMsg{})
+	return nil
+}
+
+func (m *muxModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	children := m.mux.children
+
+	switch msg := msg.(type) {
+	case muxViewMsg:
+		m.views[msg.index] = msg.view
+
+	case muxDoneMsg:
+		m.done[msg.index] = true
+		for _, done := range m.done {
+			if !done {
+				if msg.index == m.focus {
+					m.focusNext()
+				}
+				return m, nil
+			}
+		}
+		return m, tea.Quit
+
+	case muxFocusMsg:
+		if msg.index >= 0 && msg.index < len(children) && !m.done[msg.index] {
+			m.setFocus(msg.index)
+		}
+
+	case tea.WindowSizeMsg:
+		// Regions are as tall as their views, up to the limit
+		height := msg.Height
+		if m.mux.maxHeight > 0 {
+			height = min(height, m.mux.maxHeight)
+		}
+		for _, child := range children {
+			child.resize(Size{Width: msg.Width, Height: height})
+		}
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			// Quit the children, the mux follows once they are done
+			for i, child := range children {
+				if !m.done[i] {
+					child.send(tea.Quit())
+				}
+			}
+			return m, nil
+		case m.mux.focusKey:
+			m.focusNext()
+			return m, nil
+		}
+		children[m.focus].send(msg)
+
+	case KeyEventMsg, tea.FocusMsg, tea.BlurMsg, tea.MouseMsg:
+		children[m.focus].send(msg)
+	}
+	return m, nil
+}
+
+func (m *muxModel) View() string {
+	var lines []string
+	for _, view := range m.views {
+		if view == "" {
+			continue
+		}
+		lines = append(lines, clipHeight(view, m.mux.maxHeight)...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// clipHeight splits view into lines, keeping at most height of them; a zero
+// height keeps all
+func clipHeight(view string, height int) []string {
+	lines := strings.Split(view, "\n")
+	if height > 0 && len(lines) > height {
+		return lines[:height]
+	}
+	return lines
+}
+
+// focusNext moves focus to the next child that is still running
+func (m *muxModel) focusNext() {
+	for step := 1; step < len(m.views); step++ {
+		next := (m.focus + step) % len(m.views)
+		if !m.done[next] {
+			m.setFocus(next)
+			return
+		}
+	}
+}
+
+// setFocus blurs the focused child and focuses the one at index
+func (m *muxModel) setFocus(index int) {
+	if index == m.focus {
+		return
+	}
+	children := m.mux.children
+	children[m.focus].send(tea.BlurMsg{})
+	m.focus = index
+	children[m.focus].send(tea.FocusMsg{})
+}
@@ -0,0 +1,191 @@
+package brew
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// jobModel views a fixed set of lines and quits once it is told it is done
+type jobModel struct {
+	lines []string
+}
+
+// jobDone makes a jobModel quit
+type jobDone struct{}
+
+func (m jobModel) Init() tea.Cmd { return nil }
+
+func (m jobModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if _, ok := msg.(jobDone); ok {
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m jobModel) View() string { return strings.Join(m.lines, "\n") }
+
+// newTestMux creates a mux of programs viewing the given lines, without
+// starting it
+func newTestMux(views ...[]string) (*Mux, *muxModel) {
+	var programs []*Program
+	for _, lines := range views {
+		programs = append(programs, NewProgram(jobModel{lines: lines}))
+	}
+	m := NewMux(programs...)
+	return m, m.host.model.(*muxModel)
+}
+
+// queued returns the messages waiting to be forwarded to a child
+func queued(c *muxChild) []Msg {
+	var msgs []Msg
+	for msg, ok := c.inbox.pop(); ok; msg, ok = c.inbox.pop() {
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func TestMuxView(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxHeight int
+		views     []string
+		want      string
+	}{
+		{"regions as tall as their views", 0, []string{"one", "two\nlines", "three"}, "one\ntwo\nlines\nthree"},
+		{"empty regions take no room", 0, []string{"one", "", "three"}, "one\nthree"},
+		{"max height clips", 2, []string{"a\nb\nc", "d", "e\nf"}, "a\nb\nd\ne\nf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, model := newTestMux(nil, nil, nil)
+			m.WithMaxHeight(tt.maxHeight)
+			for i, view := range tt.views {
+				model.Update(muxViewMsg{index: i, view: view})
+			}
+			if got := model.View(); got != tt.want {
+				t.Errorf("View() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMuxResize(t *testing.T) {
+	for _, maxHeight := range []int{0, 3} {
+		m, model := newTestMux(nil, nil)
+		m.WithMaxHeight(maxHeight)
+		model.Update(tea.WindowSizeMsg{Width: 40, Height: 10})
+
+		want := Size{Width: 40, Height: 10}
+		if maxHeight > 0 {
+			want.Height = maxHeight
+		}
+		for i, child := range m.children {
+			if got, _ := child.regionSize(); got != want {
+				t.Errorf("max %d: region %d = %+v, want %+v", maxHeight, i, got, want)
+			}
+			if got := queued(child); !slices.Equal(got, []Msg{tea.WindowSizeMsg{Width: want.Width, Height: want.Height}}) {
+				t.Errorf("max %d: child %d got %#v", maxHeight, i, got)
+			}
+		}
+	}
+}
+
+func TestMuxKeys(t *testing.T) {
+	m, model := newTestMux(nil, nil, nil)
+	model.Init()
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	model.Update(tea.KeyMsg{Type: tea.KeyCtrlN})
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'b'}})
+
+	want := [][]Msg{
+		{tea.FocusMsg{}, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}}, tea.BlurMsg{}},
+		{tea.FocusMsg{}, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'b'}}},
+		nil,
+	}
+	for i, child := range m.children {
+		if got := queued(child); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("child %d got %#v, want %#v", i, got, want[i])
+		}
+	}
+
+	// ctrl+c quits every child still running, not just the focused one
+	model.Update(muxDoneMsg{index: 2})
+	if _, cmd := model.Update(tea.KeyMsg{Type: tea.KeyCtrlC}); cmd != nil {
+		t.Error("mux quit before its children")
+	}
+	for i, child := range m.children {
+		got := queued(child)
+		if i < 2 && !slices.Equal(got, []Msg{tea.QuitMsg{}}) {
+			t.Errorf("child %d got %#v, want quit", i, got)
+		}
+		if i == 2 && len(got) != 0 {
+			t.Errorf("finished child %d got %#v", i, got)
+		}
+	}
+}
+
+func TestMuxForwardDoesNotBlock(t *testing.T) {
+	m, model := newTestMux(nil)
+	done := make(chan struct{})
+	go func() {
+		// Far more than the child's priority lane holds, with nothing reading
+		for range 10 * priorityBuffer {
+			model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("forwarding keys blocked the host")
+	}
+	if got := m.children[0].inbox.len(); got != defaultMessageBuffer {
+		t.Errorf("queued %d messages, want %d", got, defaultMessageBuffer)
+	}
+}
+
+func TestMuxRun(t *testing.T) {
+	m, _ := newTestMux([]string{"web: starting"}, []string{"db: starting", "db: migrating"})
+	m.WithMaxHeight(1)
+
+	screen := NewVirtualTerminal(20, 5)
+	host := m.Host().WithRawMode(false).WithCursorHidden(false)
+	host.input = strings.NewReader("")
+	host.terminal = NewTerminal().
+		WithOutput(screen).
+		WithOutputMode(OutputInteractive).
+		WithSizeFunc(func() (Size, error) { return screen.Size(), nil })
+
+	go func() {
+		// Let both children render before they quit
+		time.Sleep(50 * time.Millisecond)
+		for _, child := range m.children {
+			child.program.Send(jobDone{})
+		}
+	}()
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := m.Run()
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		m.host.Kill()
+		t.Fatal("mux did not quit after its children")
+	}
+
+	want := []string{"web: starting", "db: starting", "", "", ""}
+	if got := screen.Screen(); !slices.Equal(got, want) {
+		t.Errorf("screen = %q, want %q", got, want)
+	}
+}
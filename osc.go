@@ -0,0 +1,114 @@
+package brew
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// ClipboardMsg is the reply to ReadClipboard
+type ClipboardMsg struct {
+	Content string
+}
+
+func (ClipboardMsg) queryName() string { return "clipboard" }
+
+// terminalWriteMsg asks the runtime to write to the terminal between frames
+type terminalWriteMsg struct {
+	write func(t *Terminal)
+}
+
+// writeSequence creates a command writing seq to the terminal
+func writeSequence(seq string) Cmd {
+	return func() Msg {
+		return terminalWriteMsg{write: func(t *Terminal) { t.WriteSequence(seq) }}
+	}
+}
+
+// SetClipboard copies text to the system clipboard using OSC 52, which also
+// works over SSH in terminals that allow it
+func SetClipboard(text string) Cmd {
+	return writeSequence("\033]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a")
+}
+
+// ReadClipboard asks the terminal for the clipboard contents. The answer
+// arrives as a ClipboardMsg, or a QueryTimeoutMsg for terminals that do not
+// allow reading it, which is the common default.
+func ReadClipboard() Cmd {
+	return query("clipboard", "\033]52;c;?\a")
+}
+
+// parseClipboard decodes the value of an OSC 52 reply, "<selection>;<base64>"
+func parseClipboard(value string) (Msg, bool) {
+	_, data, ok := strings.Cut(value, ";")
+	if !ok {
+		return nil, false
+	}
+	content, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, false
+	}
+	return ClipboardMsg{Content: string(content)}, true
+}
+
+// ProgressState is the state shown by a taskbar or tab progress indicator
+type ProgressState int
+
+const (
+	ProgressNone ProgressState = iota
+	ProgressNormal
+	ProgressError
+	ProgressIndeterminate
+	ProgressWarning
+)
+
+// Bell rings the terminal bell, which many terminals turn into a visual or
+// desktop alert
+func Bell() Cmd {
+	return writeSequence("\a")
+}
+
+// Notify shows a desktop notification using OSC 9, supported by e.g. iTerm2,
+// kitty, WezTerm and Windows Terminal
+func Notify(body string) Cmd {
+	return writeSequence("\033]9;" + oscText(body) + "\a")
+}
+
+// NotifyWithTitle shows a desktop notification with a title using OSC 777,
+// supported by e.g. foot, Ghostty, WezTerm and urxvt
+func NotifyWithTitle(title, body string) Cmd {
+	title = strings.ReplaceAll(oscText(title), ";", ",")
+	return writeSequence("\033]777;notify;" + title + ";" + oscText(body) + "\a")
+}
+
+// SetProgress shows progress in the taskbar or tab using OSC 9;4. The percent
+// is ignored for ProgressNone and ProgressIndeterminate. Progress still shown
+// when the program exits is cleared.
+func SetProgress(state ProgressState, percent int) Cmd {
+	return func() Msg {
+		return terminalWriteMsg{write: func(t *Terminal) { t.SetProgress(state, percent) }}
+	}
+}
+
+// ClearProgress removes the progress indicator
+func ClearProgress() Cmd {
+	return SetProgress(ProgressNone, 0)
+}
+
+// SetProgress writes an OSC 9;4 progress report
+func (t *Terminal) SetProgress(state ProgressState, percent int) {
+	if !t.Interactive() {
+		return
+	}
+	t.printf("\033]9;4;%d;%d\a", state, clamp(percent, 0, 100))
+	t.progress = state != ProgressNone
+}
+
+// oscText removes control characters, which would end the sequence early
+func oscText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || (r >= 0x80 && r < 0xa0) {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,77 @@
+package brew
+
+import (
+	"bytes"
+	"testing"
+)
+
+// written returns what the terminal command cmd writes to an interactive
+// terminal
+func written(t *testing.T, cmd Cmd) string {
+	t.Helper()
+	msg, ok := cmd().(terminalWriteMsg)
+	if !ok {
+		t.Fatalf("command returned %T, want terminalWriteMsg", cmd())
+	}
+	var buf bytes.Buffer
+	msg.write(NewTerminal().WithOutput(&buf).WithOutputMode(OutputInteractive))
+	return buf.String()
+}
+
+func TestNotifications(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  Cmd
+		want string
+	}{
+		{"bell", Bell(), "\a"},
+		{"OSC 9", Notify("Build finished"), "\x1b]9;Build finished\a"},
+		{"OSC 9 control characters", Notify("a\x07b\x1b\\c\nd\u0085"), "\x1b]9;ab\\cd\a"},
+		{"OSC 777", NotifyWithTitle("Build", "done"), "\x1b]777;notify;Build;done\a"},
+		{"OSC 777 separators in title", NotifyWithTitle("a;b", "c;d"), "\x1b]777;notify;a,b;c;d\a"},
+		{"OSC 777 control characters", NotifyWithTitle("t\a", "b\x1b"), "\x1b]777;notify;t;b\a"},
+		{"OSC 9;4", SetProgress(ProgressNormal, 42), "\x1b]9;4;1;42\a"},
+		{"OSC 9;4 error", SetProgress(ProgressError, 10), "\x1b]9;4;2;10\a"},
+		{"OSC 9;4 above 100", SetProgress(ProgressWarning, 150), "\x1b]9;4;4;100\a"},
+		{"OSC 9;4 below 0", SetProgress(ProgressNormal, -5), "\x1b]9;4;1;0\a"},
+		{"OSC 9;4 indeterminate", SetProgress(ProgressIndeterminate, 0), "\x1b]9;4;3;0\a"},
+		{"clear progress", ClearProgress(), "\x1b]9;4;0;0\a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := written(t, tt.cmd); got != tt.want {
+				t.Errorf("wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProgressClearedOnFinish(t *testing.T) {
+	var buf bytes.Buffer
+	term := NewTerminal().WithOutput(&buf).WithOutputMode(OutputInteractive)
+
+	term.SetProgress(ProgressNormal, 50)
+	term.SetProgress(ProgressNone, 0)
+	buf.Reset()
+	term.Finish()
+	if got := buf.String(); got != "\n" {
+		t.Errorf("Finish after clearing wrote %q, want %q", got, "\n")
+	}
+
+	term.SetProgress(ProgressNormal, 50)
+	buf.Reset()
+	term.Finish()
+	if got, want := buf.String(), "\x1b]9;4;0;0\a\n"; got != want {
+		t.Errorf("Finish with progress shown wrote %q, want %q", got, want)
+	}
+}
+
+func TestSequencesNotWrittenToLogs(t *testing.T) {
+	for _, cmd := range []Cmd{Bell(), Notify("a"), NotifyWithTitle("a", "b"), SetProgress(ProgressNormal, 1)} {
+		var buf bytes.Buffer
+		cmd().(terminalWriteMsg).write(NewTerminal().WithOutput(&buf).WithOutputMode(OutputAppend))
+		if buf.Len() > 0 {
+			t.Errorf("non-interactive terminal got %q", buf.String())
+		}
+	}
+}
@@ -3,8 +3,14 @@ package brew
 import (
 	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -23,10 +29,23 @@ type Sub func(ctx context.Context, send func(Msg))
 type ColdbrewModel interface {
 	tea.Model
 
-	// Subscriptions returns active subscriptions (optional)
+	// Subscriptions returns active subscriptions (optional). It is called again
+	// after every update; subscriptions are matched by the function they are,
+	// and subscriptions created by the same function, e.g. two WatchFile
+	// calls, by their order. Use KeyedModel for stable identities.
 	Subscriptions() []Sub
 }
 
+// CursorModel is implemented by models that show a text cursor, such as a
+// text input, so input methods and screen readers can follow the caret
+type CursorModel interface {
+	tea.Model
+
+	// Cursor returns the cursor position within the view (0-based column and
+	// line, in cells) and whether the cursor should be shown
+	Cursor() (pos Position, visible bool)
+}
+
 // Program manages the Elm architecture runtime
 type Program struct {
 	model         tea.Model
@@ -35,11 +54,56 @@ type Program struct {
 	quit          chan struct{}
 	ctx           context.Context
 	cancel        context.CancelFunc
-	subs          []Sub
+	subs          map[string]context.CancelFunc
 	hideCursor    bool
 	rawMode       bool
 	terminalState *TerminalState
 	finished      chan struct{}
+	screenDebug   bool
+	screen        *VirtualTerminal
+	frame         int
+	recordPath    string
+	recorder      *castRecorder
+	input         io.Reader
+	inputPath     string
+	inputRecorder *castRecorder
+	replayPath    string
+	tracing       bool
+	cmdSeq        atomic.Uint64
+	priority      chan Msg
+	queue         *overflowQueue
+	counters      *messageCounters
+	pending       []Msg
+	cmds          sync.WaitGroup
+	inFlight      atomic.Int64
+	drainTimeout  time.Duration
+	debounces     map[string]uint64
+	throttles     map[string]*throttleWindow
+	viewFunc      func(view string)
+	hostSend      func(msg Msg) // receives the terminal output of an embedded program
+	killed        atomic.Bool
+	keyboard      KeyboardMode
+	keyboardProto keyboardProtocol
+	keyboardDA1   bool         // DA1 sent after the keyboard query is unanswered
+	cprPending    atomic.Int32 // unanswered cursor position queries
+	escTimeout    time.Duration
+	queries       map[string]uint64
+	queryTimeout  time.Duration
+}
+
+// InlineHeightMsg reports how many rows the inline frame can use before the
+// terminal has to scroll. It is sent after the program learns the position of
+// the frame, at startup and after resizes, when running in raw mode.
+type InlineHeightMsg struct {
+	Height int
+}
+
+// cursorPositionQuery names the cursor position queries made by the runtime
+const cursorPositionQuery = "cursor position"
+
+// cursorPositionMsg is the terminal's reply to a cursor position query
+type cursorPositionMsg struct {
+	row, col int
 }
 
 // QuitMsg signals the program should exit
@@ -48,16 +112,27 @@ type QuitMsg struct{}
 // NewProgram creates a new Elm architecture program
 func NewProgram(initialModel tea.Model) *Program {
 	ctx, cancel := context.WithCancel(context.Background())
+	counters := &messageCounters{}
 	return &Program{
-		model:      initialModel,
-		terminal:   NewTerminal(),
-		msgChan:    make(chan Msg, 100),
-		quit:       make(chan struct{}),
-		ctx:        ctx,
-		cancel:     cancel,
-		hideCursor: true, // Default to hiding cursor for TUI apps
-		rawMode:    true, // Default to raw mode for immediate input
-		finished:   make(chan struct{}),
+		model:        initialModel,
+		terminal:     NewTerminal(),
+		msgChan:      make(chan Msg, defaultMessageBuffer),
+		priority:     make(chan Msg, priorityBuffer),
+		queue:        newOverflowQueue(defaultMessageBuffer, counters),
+		counters:     counters,
+		quit:         make(chan struct{}),
+		ctx:          ctx,
+		cancel:       cancel,
+		subs:         make(map[string]context.CancelFunc),
+		debounces:    make(map[string]uint64),
+		throttles:    make(map[string]*throttleWindow),
+		input:        os.Stdin,
+		escTimeout:   defaultEscapeTimeout,
+		queries:      make(map[string]uint64),
+		queryTimeout: defaultQueryTimeout,
+		hideCursor:   true, // Default to hiding cursor for TUI apps
+		rawMode:      true, // Default to raw mode for immediate input
+		finished:     make(chan struct{}),
 	}
 }
 
@@ -73,10 +148,127 @@ func (p *Program) WithRawMode(enable bool) *Program {
 	return p
 }
 
-// Send sends a message to the program
+// WithScreenDebug mirrors all terminal output into a VirtualTerminal and logs
+// the emulated screen after every frame (see InitLogging)
+func (p *Program) WithScreenDebug(enable bool) *Program {
+	p.screenDebug = enable
+	return p
+}
+
+// WithOutputMode sets how frames are written. The default, OutputAuto, falls
+// back to printing only the final frame when stdout is not a terminal.
+func (p *Program) WithOutputMode(mode OutputMode) *Program {
+	p.terminal.WithOutputMode(mode)
+	return p
+}
+
+// WithStripANSI removes escape sequences from non-interactive output
+func (p *Program) WithStripANSI(strip bool) *Program {
+	p.terminal.WithStripANSI(strip)
+	return p
+}
+
+// WithColorProfile overrides the colour profile detected from the
+// environment, e.g. to force ProfileNoColor
+func (p *Program) WithColorProfile(profile ColorProfile) *Program {
+	p.terminal.WithColorProfile(profile)
+	return p
+}
+
+// WithKeyboardMode negotiates an enhanced keyboard encoding with the terminal
+// while the program runs in raw mode; the previous encoding is restored on exit
+func (p *Program) WithKeyboardMode(mode KeyboardMode) *Program {
+	p.keyboard = mode
+	return p
+}
+
+// WithEscapeTimeout sets how long an incomplete escape sequence is waited on
+// before its ESC is delivered as an Esc key press (default 50ms)
+func (p *Program) WithEscapeTimeout(d time.Duration) *Program {
+	p.escTimeout = d
+	return p
+}
+
+// WithQueryTimeout sets how long terminal queries such as
+// RequestBackgroundColor wait for a reply before a QueryTimeoutMsg is sent
+// (default 1s)
+func (p *Program) WithQueryTimeout(d time.Duration) *Program {
+	p.queryTimeout = d
+	return p
+}
+
+// WithRecording tees all terminal output, including resizes, to an asciicast
+// v2 file at path so the session can be replayed with asciinema
+func (p *Program) WithRecording(path string) *Program {
+	p.recordPath = path
+	return p
+}
+
+// WithInputRecording records the raw input stream and terminal size changes,
+// with their timing, to path so the session can be replayed later
+func (p *Program) WithInputRecording(path string) *Program {
+	p.inputPath = path
+	return p
+}
+
+// WithInputReplay reads input from a file written by WithInputRecording
+// instead of stdin, reproducing the recorded keys and resizes
+func (p *Program) WithInputReplay(path string) *Program {
+	p.replayPath = path
+	return p
+}
+
+// WithTracing logs every dispatched message, command, subscription and render
+// as structured slog records, to diagnose why and how often the program
+// re-renders (see InitLogging)
+func (p *Program) WithTracing(enable bool) *Program {
+	p.tracing = enable
+	return p
+}
+
+// WithMessageBuffer sets the capacity of the message channel and of the queue
+// used by subscriptions with a non-blocking OverflowPolicy (default 100).
+// Sizes below 1 are raised to 1.
+func (p *Program) WithMessageBuffer(size int) *Program {
+	size = max(size, 1)
+	p.msgChan = make(chan Msg, size)
+	p.queue = newOverflowQueue(size, p.counters)
+	return p
+}
+
+// WithShutdownTimeout makes Run wait up to d for in-flight commands to return
+// after the program quits. Their context (see ContextCmd) is cancelled first.
+func (p *Program) WithShutdownTimeout(d time.Duration) *Program {
+	p.drainTimeout = d
+	return p
+}
+
+// Send sends a message to the program. Input, resize and quit messages use a
+// separate lane so they are handled before any queued bulk messages.
 func (p *Program) Send(msg Msg) {
+	// Messages sent after the program finished are dropped
+	if p.ctx.Err() != nil {
+		return
+	}
+	p.counters.sent.Add(1)
+
+	ch := p.msgChan
+	if isPriorityMsg(msg) {
+		ch = p.priority
+	}
+
+	select {
+	case ch <- msg:
+		return
+	default:
+	}
+
+	p.counters.blocked.Add(1)
+	if p.tracing {
+		p.trace("send blocked", "msg", fmt.Sprintf("%T", msg))
+	}
 	select {
-	case p.msgChan <- msg:
+	case ch <- msg:
 	case <-p.ctx.Done():
 	}
 }
@@ -97,6 +289,7 @@ func (p *Program) Quit() {
 // The final render that you would normally see when quitting will be skipped.
 // This is compatible with bubbletea's Program.Kill method.
 func (p *Program) Kill() {
+	p.killed.Store(true)
 	p.cancel()
 }
 
@@ -111,16 +304,61 @@ func (p *Program) Wait() {
 func (p *Program) Run() (tea.Model, error) {
 	// Ensure finished channel is closed when Run exits
 	defer close(p.finished)
+
+	// Give in-flight commands a chance to finish once everything else is done
+	defer p.drainCmds()
+
+	// Replace stdin and the terminal size with a recorded session
+	if p.replayPath != "" {
+		replay, err := loadInputReplay(p.replayPath)
+		if err != nil {
+			return p.model, err
+		}
+		replay.ctx = p.ctx
+		replay.onResize = func() { p.Send(windowSizeMsg{}) }
+		p.input = replay
+		p.terminal.WithSizeFunc(replay.Size)
+	}
+
+	// Record the input stream for later replay
+	if p.inputPath != "" {
+		size, _ := p.terminal.GetSize()
+		recorder, err := newCastRecorder(p.inputPath, size)
+		if err != nil {
+			return p.model, err
+		}
+		p.inputRecorder = recorder
+		defer p.inputRecorder.Close()
+		p.input = io.TeeReader(p.input, castInput{recorder: recorder})
+	}
+
+	// Setup recording first so the whole session is captured
+	if p.recordPath != "" {
+		size, _ := p.terminal.GetSize()
+		recorder, err := newCastRecorder(p.recordPath, size)
+		if err != nil {
+			return p.model, err
+		}
+		p.recorder = recorder
+		defer p.recorder.Close()
+		p.terminal.WithOutput(io.MultiWriter(p.terminal.out, p.recorder))
+	}
 	
+	// Print a final newline to position cursor properly for shell prompt,
+	// or the final frame when the output is not interactive and the program
+	// was not killed
+	defer func() {
+		if p.killed.Load() && !p.terminal.Interactive() {
+			return
+		}
+		p.terminal.Finish()
+	}()
+
 	// Setup cursor visibility
 	if p.hideCursor {
 		p.terminal.HideCursor()
 		// Ensure cursor is restored on exit
-		defer func() {
-			p.terminal.ShowCursor()
-			// Print a final newline to position cursor properly for shell prompt
-			fmt.Print("\n")
-		}()
+		defer p.terminal.ShowCursor()
 	}
 
 	// Setup raw mode if enabled
@@ -136,33 +374,35 @@ func (p *Program) Run() (tea.Model, error) {
 		}
 	}
 
+	// Negotiate the enhanced keyboard encoding, restoring the previous one on exit
+	if p.rawMode && p.keyboard != KeyboardLegacy {
+		p.negotiateKeyboard()
+		defer p.restoreKeyboard()
+	}
+
+	// Mirror output into an emulator when debugging the rendered screen
+	if p.screenDebug {
+		size, _ := p.terminal.GetSize()
+		p.screen = NewVirtualTerminal(size.Width, size.Height)
+		p.terminal.WithOutput(io.MultiWriter(p.terminal.out, p.screen))
+	}
+
+	// Tell the model what the terminal supports as the first message it
+	// receives, ahead of the priority lane and without blocking on the channel
+	p.pending = append(p.pending, CapabilitiesMsg{Capabilities: p.terminal.Capabilities()})
+
+	// Find where the frame will start before anything is drawn
+	p.queryCursorPosition()
+
 	// Send initial window size
 	go p.checkResize()
 
 	// Start listening for window resize events
 	go p.handleResize()
 
-	// Initialize the model
-	cmd := p.model.Init()
-
-	// Execute initial command if any
-	if cmd != nil {
-		go func() {
-			if msg := cmd(); msg != nil {
-				p.Send(msg)
-			}
-		}()
-	}
-
-	// Initial render
-	p.render()
-
 	// Start input handling
 	go p.handleInput()
 
-	// Start subscriptions
-	p.startSubscriptions()
-
 	// Handle interrupt signals for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -171,111 +411,285 @@ func (p *Program) Run() (tea.Model, error) {
 		p.Send(tea.Quit())
 	}()
 
+	return p.run()
+}
+
+// run initialises the model and runs the message loop until the program quits
+func (p *Program) run() (tea.Model, error) {
+	// Initialize the model
+	cmd := p.model.Init()
+
+	// Execute initial command if any
+	p.runCmd(cmd)
+
+	// Initial render
+	p.render()
+
+	// Start subscriptions
+	p.syncSubscriptions()
+
 	// Main message loop
 	for {
-		select {
-		case msg := <-p.msgChan:
-			// Handle quit messages - both tea.Quit and custom QuitMsg
-			if _, isQuit := msg.(QuitMsg); isQuit {
-				p.cancel()
-				return p.model, nil
-			}
-			if _, isTeaQuit := msg.(tea.QuitMsg); isTeaQuit {
-				p.cancel()
-				return p.model, nil
-			}
+		msg, ok := p.nextMsg()
+		if !ok {
+			return p.model, nil
+		}
+		msg = p.coalesce(msg)
+		if p.tracing {
+			p.trace("dispatch", "msg", fmt.Sprintf("%T", msg))
+		}
 
-			// Handle windowSizeMsg (internal message to trigger size check)
-			if _, isWindowSizeMsg := msg.(windowSizeMsg); isWindowSizeMsg {
-				go p.checkResize()
-				continue
-			}
+		updated, quit := p.dispatch(msg)
+		if quit {
+			p.cancel()
+			return p.model, nil
+		}
 
-			// Handle enableReportFocusMsg (enable focus reporting)
-			if _, isEnableFocus := msg.(enableReportFocusMsg); isEnableFocus {
-				p.terminal.EnableReportFocus()
-				continue
-			}
+		// Re-render
+		if updated {
+			p.render()
+		}
 
-			// Handle bubbletea's enableReportFocusMsg (check by comparing with tea.EnableReportFocus())
-			if fmt.Sprintf("%T", msg) == fmt.Sprintf("%T", tea.EnableReportFocus()) {
-				p.terminal.EnableReportFocus()
-				continue
-			}
+		// Locate the frame again, a resize may have moved it
+		if _, resized := msg.(tea.WindowSizeMsg); resized {
+			p.queryCursorPosition()
+		}
+	}
+}
 
-			// Handle disableReportFocusMsg (disable focus reporting)
-			if _, isDisableFocus := msg.(disableReportFocusMsg); isDisableFocus {
-				p.terminal.DisableReportFocus()
-				continue
-			}
+// queryCursorPosition asks the terminal where the cursor is. Only in raw mode
+// is the reply read from the input rather than echoed onto the screen.
+func (p *Program) queryCursorPosition() {
+	if !p.rawMode || p.viewFunc != nil || !p.terminal.Interactive() {
+		return
+	}
+	p.terminal.QueryCursorPosition()
+	p.cprPending.Add(1)
+	p.awaitReply(cursorPositionQuery)
+}
 
-			// Handle bubbletea's disableReportFocusMsg (check by comparing with tea.DisableReportFocus())
-			if fmt.Sprintf("%T", msg) == fmt.Sprintf("%T", tea.DisableReportFocus()) {
-				p.terminal.DisableReportFocus()
-				continue
-			}
+// takeCursorQuery claims an unanswered cursor position query for a reply read
+// from the input. Without one, the reply is a key press sharing its encoding.
+func (p *Program) takeCursorQuery() bool {
+	for {
+		n := p.cprPending.Load()
+		if n <= 0 {
+			return false
+		}
+		if p.cprPending.CompareAndSwap(n, n-1) {
+			return true
+		}
+	}
+}
 
-			// Handle tea.BatchMsg (bubbletea's batch commands)
-			if teaBatchMsg, isTeaBatch := msg.(tea.BatchMsg); isTeaBatch {
-				for _, cmd := range teaBatchMsg {
-					if cmd != nil {
-						go func(cmd tea.Cmd) {
-							if cmdMsg := cmd(); cmdMsg != nil {
-								p.Send(cmdMsg)
-							}
-						}(cmd)
-					}
-				}
-				continue
-			}
+// dispatch handles a single message without rendering. It reports whether
+// the model was updated and whether the program should quit.
+func (p *Program) dispatch(msg Msg) (updated bool, quit bool) {
+	// Handle debounced and throttled messages, delivering the wrapped
+	// message only when it is due
+	if timed, isTimed := msg.(timedMsg); isTimed {
+		inner, deliver := p.handleTimed(timed)
+		if !deliver {
+			return false, false
+		}
+		msg = inner
+	}
 
-			// Handle coldbrew BatchMsg (legacy)
-			if batchMsg, isBatch := msg.(BatchMsg); isBatch {
-				for _, batchedMsg := range batchMsg.Messages {
-					// Process each batched message synchronously
-					newModel, newCmd := p.model.Update(batchedMsg)
-					p.model = newModel
-
-					// Execute command if any
-					if newCmd != nil {
-						go func() {
-							if cmdMsg := newCmd(); cmdMsg != nil {
-								p.Send(cmdMsg)
-							}
-						}()
-					}
-				}
-				// Re-render after all batch messages processed
-				p.render()
-				continue
-			}
+	// Handle quit messages - both tea.Quit and custom QuitMsg
+	if _, isQuit := msg.(QuitMsg); isQuit {
+		return false, true
+	}
+	if _, isTeaQuit := msg.(tea.QuitMsg); isTeaQuit {
+		return false, true
+	}
 
-			// Update model
-			newModel, newCmd := p.model.Update(msg)
-			p.model = newModel
-
-			// Execute command if any
-			if newCmd != nil {
-				go func() {
-					if cmdMsg := newCmd(); cmdMsg != nil {
-						p.Send(cmdMsg)
-					}
-				}()
-			}
+	// Handle transactions, including the legacy BatchMsg
+	if tx, isTx := msg.(TransactionMsg); isTx {
+		return p.applyTransaction(tx)
+	}
 
-			// Re-render
-			p.render()
+	// Handle output committed above the frame
+	if printMsg, isPrint := msg.(printLineMsg); isPrint {
+		p.commit(printMsg.text)
+		return false, false
+	}
 
-		case <-p.ctx.Done():
-			return p.model, nil
+	// Handle bubbletea's Println/Printf (check by type name, the message type is unexported)
+	if fmt.Sprintf("%T", msg) == fmt.Sprintf("%T", tea.Println()()) {
+		p.commit(reflect.ValueOf(msg).FieldByName("messageBody").String())
+		return false, false
+	}
+
+	// Handle context-aware commands
+	if ctxCmd, isCtxCmd := msg.(contextCmdMsg); isCtxCmd {
+		p.runContextCmd(ctxCmd.fn)
+		return false, false
+	}
+
+	// Handle terminal queries, their replies and timeouts
+	if p.handleKeyboardReply(msg) {
+		return false, false
+	}
+	switch m := msg.(type) {
+	case queryMsg:
+		timeout, deliver := p.handleQuery(m)
+		if !deliver {
+			return false, false
+		}
+		msg = timeout
+	case queryTimeoutFireMsg:
+		if p.queries[m.name] != m.generation {
+			// Answered, or superseded by a later query
+			return false, false
+		}
+		delete(p.queries, m.name)
+		if m.name == cursorPositionQuery {
+			// The terminal does not answer, stop waiting for the replies
+			p.cprPending.Store(0)
+			p.terminal.CancelCursorQueries()
+			return false, false
+		}
+		msg = QueryTimeoutMsg{Query: m.name}
+	case queryReply:
+		delete(p.queries, m.queryName())
+	}
+
+	// Write escape sequences such as clipboard updates between frames, through
+	// the program owning the terminal when embedded
+	if w, isWrite := msg.(terminalWriteMsg); isWrite {
+		if p.hostSend != nil {
+			p.hostSend(w)
+			return false, false
+		}
+		w.write(p.terminal)
+		return false, false
+	}
+
+	// Handle cursor position reports, telling the model how much room the
+	// inline frame has
+	if pos, isPos := msg.(cursorPositionMsg); isPos {
+		if p.cprPending.Load() == 0 {
+			delete(p.queries, cursorPositionQuery)
+		}
+		p.terminal.SetCursorPosition(pos.row, pos.col)
+		height, ok := p.terminal.InlineHeight()
+		if !ok {
+			return false, false
 		}
+		msg = InlineHeightMsg{Height: height}
+	}
+
+	// Handle windowSizeMsg (internal message to trigger size check)
+	if _, isWindowSizeMsg := msg.(windowSizeMsg); isWindowSizeMsg {
+		go p.checkResize()
+		return false, false
+	}
+
+	// Handle enableReportFocusMsg (enable focus reporting)
+	if _, isEnableFocus := msg.(enableReportFocusMsg); isEnableFocus {
+		p.terminal.EnableReportFocus()
+		return false, false
+	}
+
+	// Handle bubbletea's enableReportFocusMsg (check by comparing with tea.EnableReportFocus())
+	if fmt.Sprintf("%T", msg) == fmt.Sprintf("%T", tea.EnableReportFocus()) {
+		p.terminal.EnableReportFocus()
+		return false, false
+	}
+
+	// Handle disableReportFocusMsg (disable focus reporting)
+	if _, isDisableFocus := msg.(disableReportFocusMsg); isDisableFocus {
+		p.terminal.DisableReportFocus()
+		return false, false
+	}
+
+	// Handle bubbletea's disableReportFocusMsg (check by comparing with tea.DisableReportFocus())
+	if fmt.Sprintf("%T", msg) == fmt.Sprintf("%T", tea.DisableReportFocus()) {
+		p.terminal.DisableReportFocus()
+		return false, false
+	}
+
+	// Handle tea.BatchMsg (bubbletea's batch commands), which run concurrently
+	// and each deliver their message separately
+	if teaBatchMsg, isTeaBatch := msg.(tea.BatchMsg); isTeaBatch {
+		for _, cmd := range teaBatchMsg {
+			p.runCmd(cmd)
+		}
+		return false, false
+	}
+
+	// Update model
+	newModel, newCmd := p.model.Update(msg)
+	p.model = newModel
+	p.syncSubscriptions()
+
+	// Execute command if any
+	p.runCmd(newCmd)
+	return true, false
+}
+
+// applyTransaction dispatches every message of a transaction in order, with
+// nothing else interleaved and without rendering in between. A quit message
+// stops the transaction.
+func (p *Program) applyTransaction(tx TransactionMsg) (updated bool, quit bool) {
+	for _, msg := range tx.Messages {
+		msgUpdated, msgQuit := p.dispatch(msg)
+		updated = updated || msgUpdated
+		if msgQuit {
+			return updated, true
+		}
+	}
+	return updated, false
+}
+
+// trace emits a debug record when tracing is enabled
+func (p *Program) trace(msg string, args ...any) {
+	if p.tracing {
+		slog.Debug(msg, args...)
 	}
 }
 
 // render renders the current model to the terminal
 func (p *Program) render() {
+	start := time.Now()
 	viewString := p.model.View()
+
+	// Embedded programs hand their view to whoever owns the terminal
+	if p.viewFunc != nil {
+		p.viewFunc(viewString)
+		p.trace("render", "mode", "embedded", "bytes", len(viewString), "duration", time.Since(start))
+		return
+	}
+
+	// Place the real cursor where the model wants it
+	var cursor *Position
+	if cm, ok := p.model.(CursorModel); ok {
+		if pos, visible := cm.Cursor(); visible {
+			cursor = &pos
+		}
+	}
+	p.terminal.SetCursor(cursor)
 	p.terminal.RenderString(viewString)
+
+	stats := p.terminal.lastRender
+	p.trace("render",
+		"mode", stats.Mode,
+		"first_line", stats.FirstLine,
+		"lines", stats.Lines,
+		"bytes", stats.Bytes,
+		"duration", time.Since(start),
+	)
+
+	if p.screen != nil {
+		p.frame++
+		cursor := p.screen.Cursor()
+		slog.Info("screen",
+			"frame", p.frame,
+			"cursor", fmt.Sprintf("%d,%d", cursor.X, cursor.Y),
+			"scrollback", len(p.screen.Scrollback()),
+			"screen", strings.Join(p.screen.Screen(), "\n"),
+		)
+	}
 }
 
 // Quit creates a command that quits the program
@@ -285,32 +699,101 @@ func Quit() Cmd {
 	}
 }
 
-// BatchMsg represents multiple messages to be processed
-type BatchMsg struct {
+// TransactionMsg applies several messages atomically: they are dispatched in
+// order with no other message in between, and the view is rendered once
+// afterwards. Commands returned by each Update run concurrently, like
+// tea.Batch.
+type TransactionMsg struct {
 	Messages []Msg
 }
 
-// Batch creates a command that sends multiple messages
-func Batch(messages ...Msg) Cmd {
+// Transaction creates a command that applies messages as a single transaction
+func Transaction(messages ...Msg) Cmd {
 	return func() Msg {
-		return BatchMsg{Messages: messages}
+		return TransactionMsg{Messages: messages}
 	}
 }
 
-// Delay creates a command that sends a message after a delay
+// BatchMsg represents multiple messages to be processed
+//
+// Deprecated: BatchMsg is a TransactionMsg; use Transaction. For batching
+// commands use tea.Batch.
+type BatchMsg = TransactionMsg
+
+// Batch creates a command that sends multiple messages
+//
+// Deprecated: Batch batches messages, not commands like tea.Batch; use
+// Transaction instead.
+func Batch(messages ...Msg) Cmd {
+	return Transaction(messages...)
+}
+
+// Delay creates a command that sends a message after a delay. The timer is
+// stopped when the program quits. Like every ContextCmd, the command must be
+// run by a Program: called directly, e.g. in a test, it returns an internal
+// message instead of msg.
 func Delay(duration time.Duration, msg Msg) Cmd {
+	return ContextCmd(func(ctx context.Context) Msg {
+		timer := time.NewTimer(duration)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			return msg
+		case <-ctx.Done():
+			return nil
+		}
+	})
+}
+
+// Tick creates a command that sends a message after a duration. The timer is
+// stopped when the program quits. Like Delay, it only sends the message when
+// run by a Program.
+func Tick(duration time.Duration, msgFunc func(time.Time) Msg) Cmd {
+	return ContextCmd(func(ctx context.Context) Msg {
+		timer := time.NewTimer(duration)
+		defer timer.Stop()
+
+		select {
+		case t := <-timer.C:
+			return msgFunc(t)
+		case <-ctx.Done():
+			return nil
+		}
+	})
+}
+
+// Println creates a command that prints a line above the program's frame.
+// The line is committed to the terminal history and never redrawn, and is
+// the only output besides the final frame in non-interactive mode.
+// This is compatible with bubbletea's Println command
+func Println(args ...any) Cmd {
 	return func() Msg {
-		time.Sleep(duration)
-		return msg
+		return printLineMsg{text: fmt.Sprint(args...)}
 	}
 }
 
-// Tick creates a command that sends a message after a duration
-func Tick(duration time.Duration, msgFunc func(time.Time) Msg) Cmd {
+// Printf is like Println but formats the line
+// This is compatible with bubbletea's Printf command
+func Printf(format string, args ...any) Cmd {
 	return func() Msg {
-		time.Sleep(duration)
-		return msgFunc(time.Now())
+		return printLineMsg{text: fmt.Sprintf(format, args...)}
+	}
+}
+
+// printLineMsg is used internally to commit output above the frame
+type printLineMsg struct {
+	text string
+}
+
+// commit prints text above the frame
+func (p *Program) commit(text string) {
+	if p.hostSend != nil {
+		// Embedded programs do not own the terminal, the host prints for them
+		p.hostSend(printLineMsg{text: text})
+		return
 	}
+	p.terminal.Commit(text)
 }
 
 // WindowSize creates a command that queries the terminal for its current size
@@ -346,19 +829,6 @@ func DisableReportFocus() Cmd {
 // disableReportFocusMsg is used internally to disable focus reporting
 type disableReportFocusMsg struct{}
 
-// startSubscriptions starts all subscriptions from the model if it supports them
-func (p *Program) startSubscriptions() {
-	// Check if model supports subscriptions
-	if coldbrewModel, ok := p.model.(ColdbrewModel); ok {
-		p.subs = coldbrewModel.Subscriptions()
-
-		// Start each subscription in its own goroutine
-		for _, sub := range p.subs {
-			go sub(p.ctx, p.Send)
-		}
-	}
-}
-
 // Every creates a subscription that sends messages at regular intervals
 func Every(duration time.Duration, msgFunc func(time.Time) Msg) Sub {
 	return func(ctx context.Context, send func(Msg)) {
@@ -400,6 +870,16 @@ func (p *Program) checkResize() {
 		return
 	}
 
+	if p.screen != nil {
+		p.screen.Resize(size.Width, size.Height)
+	}
+	if p.recorder != nil {
+		p.recorder.resize(size)
+	}
+	if p.inputRecorder != nil {
+		p.inputRecorder.resize(size)
+	}
+
 	p.Send(tea.WindowSizeMsg{
 		Width:  size.Width,
 		Height: size.Height,
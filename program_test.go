@@ -0,0 +1,112 @@
+package brew
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// recordModel records the messages it is updated with
+type recordModel struct {
+	msgs *[]Msg
+}
+
+func newRecordModel() recordModel {
+	return recordModel{msgs: new([]Msg)}
+}
+
+func (m recordModel) Init() tea.Cmd { return nil }
+
+func (m recordModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	*m.msgs = append(*m.msgs, msg)
+	return m, nil
+}
+
+func (m recordModel) View() string { return "" }
+
+func TestTransaction(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     Msg
+		want    []Msg
+		updated bool
+		quit    bool
+	}{
+		{
+			name:    "messages in order",
+			msg:     Transaction(otherMsg(1), otherMsg(2), otherMsg(3))(),
+			want:    []Msg{otherMsg(1), otherMsg(2), otherMsg(3)},
+			updated: true,
+		},
+		{
+			name: "empty",
+			msg:  Transaction()(),
+		},
+		{
+			name:    "nested",
+			msg:     Transaction(otherMsg(1), Transaction(otherMsg(2), otherMsg(3))(), otherMsg(4))(),
+			want:    []Msg{otherMsg(1), otherMsg(2), otherMsg(3), otherMsg(4)},
+			updated: true,
+		},
+		{
+			name:    "quit stops the transaction",
+			msg:     Transaction(otherMsg(1), tea.Quit(), otherMsg(2))(),
+			want:    []Msg{otherMsg(1)},
+			updated: true,
+			quit:    true,
+		},
+		{
+			name:    "internal messages are handled, not delivered",
+			msg:     Transaction(enableReportFocusMsg{}, otherMsg(1))(),
+			want:    []Msg{otherMsg(1)},
+			updated: true,
+		},
+		{
+			name:    "legacy Batch",
+			msg:     Batch(otherMsg(1), otherMsg(2))(),
+			want:    []Msg{otherMsg(1), otherMsg(2)},
+			updated: true,
+		},
+		{
+			name:    "legacy BatchMsg literal",
+			msg:     BatchMsg{Messages: []Msg{otherMsg(1)}},
+			want:    []Msg{otherMsg(1)},
+			updated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := newRecordModel()
+			p := NewProgram(model)
+			defer p.cancel()
+
+			updated, quit := p.dispatch(tt.msg)
+			if updated != tt.updated || quit != tt.quit {
+				t.Errorf("dispatch = %t, %t, want %t, %t", updated, quit, tt.updated, tt.quit)
+			}
+			if !reflect.DeepEqual(*model.msgs, tt.want) {
+				t.Errorf("model got %v, want %v", *model.msgs, tt.want)
+			}
+		})
+	}
+}
+
+func TestTeaBatch(t *testing.T) {
+	model := newRecordModel()
+	p := NewProgram(model)
+	defer p.cancel()
+
+	cmd := tea.Batch(func() tea.Msg { return otherMsg(1) }, func() tea.Msg { return otherMsg(2) })
+	if updated, _ := p.dispatch(cmd()); updated {
+		t.Error("tea.BatchMsg reached the model")
+	}
+
+	// Each command delivers its message separately
+	got := []Msg{nextSent(t, p), nextSent(t, p)}
+	slices.SortFunc(got, func(a, b Msg) int { return int(a.(otherMsg) - b.(otherMsg)) })
+	if want := []Msg{otherMsg(1), otherMsg(2)}; !slices.Equal(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,303 @@
+package brew
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultQueryTimeout is how long a terminal query is waited on
+const defaultQueryTimeout = time.Second
+
+// maxStringSequenceLen bounds OSC and DCS replies, which carry clipboard
+// contents and so may be much longer than other sequences
+const maxStringSequenceLen = 1 << 20
+
+// DeviceAttributesMsg is the reply to RequestDeviceAttributes (DA1), listing
+// the conformance level and supported features of the terminal
+type DeviceAttributesMsg struct {
+	Attributes []int
+}
+
+// TerminalVersionMsg is the reply to RequestTerminalVersion (XTVERSION), e.g.
+// "kitty(0.35.2)"
+type TerminalVersionMsg struct {
+	Version string
+}
+
+// ForegroundColorMsg is the reply to RequestForegroundColor (OSC 10)
+type ForegroundColorMsg struct {
+	Color CellColor
+}
+
+// BackgroundColorMsg is the reply to RequestBackgroundColor (OSC 11)
+type BackgroundColorMsg struct {
+	Color CellColor
+}
+
+// IsDark reports whether the background is dark, to pick a light or dark theme
+func (m BackgroundColorMsg) IsDark() bool {
+	r, g, b := m.Color.RGB()
+	// Relative luminance, ITU-R BT.709
+	return 0.2126*float64(r)+0.7152*float64(g)+0.0722*float64(b) < 128
+}
+
+// ModeSetting is the state of a terminal mode reported by DECRQM
+type ModeSetting int
+
+const (
+	ModeNotRecognized ModeSetting = iota
+	ModeSet
+	ModeReset
+	ModePermanentlySet
+	ModePermanentlyReset
+)
+
+// ModeReportMsg is the reply to RequestMode (DECRQM)
+type ModeReportMsg struct {
+	Mode    int
+	Setting ModeSetting
+}
+
+// keyboardFlagsMsg is the reply to the kitty keyboard flags query, sent only
+// by terminals supporting the protocol
+type keyboardFlagsMsg struct {
+	flags int
+}
+
+// QueryTimeoutMsg is sent when the terminal did not answer a query in time,
+// or cannot be queried at all. Query names the request, e.g. "background" or
+// "mode 2026".
+type QueryTimeoutMsg struct {
+	Query string
+}
+
+// queryMsg asks the runtime to write a query to the terminal
+type queryMsg struct {
+	name string
+	seq  string
+}
+
+// queryTimeoutFireMsg is sent when the timer of a query expires
+type queryTimeoutFireMsg struct {
+	name       string
+	generation uint64
+}
+
+// queryReply is implemented by the replies, naming the query they answer
+type queryReply interface {
+	queryName() string
+}
+
+func (DeviceAttributesMsg) queryName() string { return "device attributes" }
+func (TerminalVersionMsg) queryName() string  { return "version" }
+func (ForegroundColorMsg) queryName() string  { return "foreground" }
+func (BackgroundColorMsg) queryName() string  { return "background" }
+func (m ModeReportMsg) queryName() string     { return "mode " + strconv.Itoa(m.Mode) }
+func (keyboardFlagsMsg) queryName() string    { return keyboardFlagsQuery }
+
+// RequestDeviceAttributes asks the terminal for its primary device attributes.
+// The answer arrives as a DeviceAttributesMsg.
+func RequestDeviceAttributes() Cmd {
+	return query("device attributes", "\033[c")
+}
+
+// RequestTerminalVersion asks the terminal for its name and version. The
+// answer arrives as a TerminalVersionMsg.
+func RequestTerminalVersion() Cmd {
+	return query("version", "\033[>0q")
+}
+
+// RequestForegroundColor asks the terminal for its default text colour. The
+// answer arrives as a ForegroundColorMsg.
+func RequestForegroundColor() Cmd {
+	return query("foreground", "\033]10;?\033\\")
+}
+
+// RequestBackgroundColor asks the terminal for its background colour. The
+// answer arrives as a BackgroundColorMsg.
+func RequestBackgroundColor() Cmd {
+	return query("background", "\033]11;?\033\\")
+}
+
+// RequestMode asks the terminal about a private mode, e.g. 2026 for
+// synchronized output. The answer arrives as a ModeReportMsg.
+func RequestMode(mode int) Cmd {
+	return query("mode "+strconv.Itoa(mode), fmt.Sprintf("\033[?%d$p", mode))
+}
+
+// query creates a command writing seq to the terminal
+func query(name, seq string) Cmd {
+	return func() Msg {
+		return queryMsg{name: name, seq: seq}
+	}
+}
+
+// handleQuery writes a query, or reports it as timed out straight away when
+// the reply could not be read back
+func (p *Program) handleQuery(msg queryMsg) (Msg, bool) {
+	if !p.rawMode || p.viewFunc != nil || !p.terminal.Interactive() {
+		return QueryTimeoutMsg{Query: msg.name}, true
+	}
+
+	p.terminal.Query(msg.seq)
+	p.trace("query", "name", msg.name)
+	p.awaitReply(msg.name)
+	return nil, false
+}
+
+// awaitReply starts the timeout of the query called name, superseding the
+// timeout of earlier queries with that name
+func (p *Program) awaitReply(name string) {
+	generation := p.queries[name] + 1
+	p.queries[name] = generation
+
+	p.runContextCmd(func(ctx context.Context) Msg {
+		timer := time.NewTimer(p.queryTimeout)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			return queryTimeoutFireMsg{name: name, generation: generation}
+		case <-ctx.Done():
+			return nil
+		}
+	})
+}
+
+// parseStringSequence decodes an OSC or DCS reply starting at b. Both end
+// with ST (ESC \) or, for OSC, BEL.
+func parseStringSequence(b []byte) (int, Msg) {
+	end, n := -1, 0
+	for i := 2; i < len(b); i++ {
+		if b[i] == 0x07 {
+			end, n = i, i+1
+			break
+		}
+		if b[i] == 0x1b && i+1 < len(b) && b[i+1] == '\\' {
+			end, n = i, i+2
+			break
+		}
+	}
+	if end < 0 {
+		if len(b) >= maxStringSequenceLen {
+			return len(b), nil
+		}
+		return 0, nil
+	}
+
+	body := string(b[2:end])
+	if b[1] == 'P' {
+		// XTVERSION: DCS > | <name and version> ST
+		if version, ok := strings.CutPrefix(body, ">|"); ok {
+			return n, TerminalVersionMsg{Version: version}
+		}
+		return n, nil
+	}
+
+	code, value, _ := strings.Cut(body, ";")
+	switch code {
+	case "10":
+		if c, ok := parseXColor(value); ok {
+			return n, ForegroundColorMsg{Color: c}
+		}
+	case "11":
+		if c, ok := parseXColor(value); ok {
+			return n, BackgroundColorMsg{Color: c}
+		}
+	case "52":
+		if msg, ok := parseClipboard(value); ok {
+			return n, msg
+		}
+	}
+	return n, nil
+}
+
+// parseReplyCSI decodes the replies to DA1, DECRQM and the kitty keyboard
+// flags query, whose parameters start with a private marker
+func parseReplyCSI(params string, final byte) Msg {
+	switch final {
+	case 'c':
+		// DA1: CSI ? <attributes> c
+		rest, ok := strings.CutPrefix(params, "?")
+		if !ok {
+			return nil
+		}
+		var attrs []int
+		for _, field := range strings.Split(rest, ";") {
+			if v, err := strconv.Atoi(field); err == nil {
+				attrs = append(attrs, v)
+			}
+		}
+		return DeviceAttributesMsg{Attributes: attrs}
+
+	case 'y':
+		// DECRQM: CSI ? <mode> ; <setting> $ y
+		rest, ok := strings.CutPrefix(params, "?")
+		if !ok {
+			return nil
+		}
+		rest, ok = strings.CutSuffix(rest, "$")
+		if !ok {
+			return nil
+		}
+		mode, setting, _ := strings.Cut(rest, ";")
+		m, errMode := strconv.Atoi(mode)
+		s, errSetting := strconv.Atoi(setting)
+		if errMode != nil || errSetting != nil {
+			return nil
+		}
+		return ModeReportMsg{Mode: m, Setting: ModeSetting(s)}
+
+	case 'u':
+		// Kitty keyboard flags: CSI ? <flags> u
+		flags, err := strconv.Atoi(strings.TrimPrefix(params, "?"))
+		if err != nil {
+			return nil
+		}
+		return keyboardFlagsMsg{flags: flags}
+	}
+	return nil
+}
+
+// parseXColor parses the X11 colour formats terminals answer with,
+// "rgb:<r>/<g>/<b>" with 1 to 4 hex digits per channel and "#rrggbb"
+func parseXColor(s string) (CellColor, bool) {
+	if hex, ok := strings.CutPrefix(s, "#"); ok && len(hex) == 6 {
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return CellColor{}, false
+		}
+		return RGBColor(uint8(v>>16), uint8(v>>8), uint8(v)), true
+	}
+
+	rest, ok := strings.CutPrefix(s, "rgb:")
+	if !ok {
+		// Some terminals answer with an alpha channel, rgba:r/g/b/a
+		if rest, ok = strings.CutPrefix(s, "rgba:"); !ok {
+			return CellColor{}, false
+		}
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) < 3 {
+		return CellColor{}, false
+	}
+
+	var channels [3]uint8
+	for i := range channels {
+		digits := parts[i]
+		if len(digits) == 0 || len(digits) > 4 {
+			return CellColor{}, false
+		}
+		v, err := strconv.ParseUint(digits, 16, 16)
+		if err != nil {
+			return CellColor{}, false
+		}
+		// Scale from the channel's precision to 8 bits
+		maxValue := uint64(1)<<(4*len(digits)) - 1
+		channels[i] = uint8(v * 255 / maxValue)
+	}
+	return RGBColor(channels[0], channels[1], channels[2]), true
+}
@@ -0,0 +1,113 @@
+package brew
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseReplyCSI(t *testing.T) {
+	tests := []struct {
+		params string
+		final  byte
+		want   Msg
+	}{
+		{"?62;22;52", 'c', DeviceAttributesMsg{Attributes: []int{62, 22, 52}}},
+		{"?1", 'c', DeviceAttributesMsg{Attributes: []int{1}}},
+		{"62;22", 'c', nil},
+		{"?2026;2$", 'y', ModeReportMsg{Mode: 2026, Setting: ModeReset}},
+		{"?2026;0$", 'y', ModeReportMsg{Mode: 2026, Setting: ModeNotRecognized}},
+		{"?1004;1$", 'y', ModeReportMsg{Mode: 1004, Setting: ModeSet}},
+		{"?2026;2", 'y', nil},
+		{"2026;2$", 'y', nil},
+		{"?x;2$", 'y', nil},
+		{"?15", 'u', keyboardFlagsMsg{flags: 15}},
+		{"?0", 'u', keyboardFlagsMsg{flags: 0}},
+		{"?", 'u', nil},
+		{"?1", 'x', nil},
+	}
+	for _, tt := range tests {
+		if got := parseReplyCSI(tt.params, tt.final); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseReplyCSI(%q, %q) = %#v, want %#v", tt.params, tt.final, got, tt.want)
+		}
+	}
+}
+
+func TestParseStringSequence(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		n    int
+		want Msg
+	}{
+		{"background ST", "\x1b]11;rgb:ffff/0000/8080\x1b\\", 25, BackgroundColorMsg{Color: RGBColor(255, 0, 128)}},
+		{"foreground BEL", "\x1b]10;rgb:00/ff/00\x07x", 18, ForegroundColorMsg{Color: RGBColor(0, 255, 0)}},
+		{"clipboard", "\x1b]52;c;aGk=\x07", 12, ClipboardMsg{Content: "hi"}},
+		{"bad clipboard", "\x1b]52;c;!!\x07", 10, nil},
+		{"unknown code", "\x1b]4;1;rgb:0/0/0\x07", 16, nil},
+		{"bad colour", "\x1b]11;blue\x07", 10, nil},
+		{"version", "\x1bP>|kitty(0.35.2)\x1b\\", 19, TerminalVersionMsg{Version: "kitty(0.35.2)"}},
+		{"other DCS", "\x1bP1$r0m\x1b\\", 9, nil},
+		{"incomplete", "\x1b]11;rgb:ffff/00", 0, nil},
+		{"incomplete ST", "\x1b]11;rgb:ffff/0000/0000\x1b", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, msg := parseStringSequence([]byte(tt.in))
+			if n != tt.n || !reflect.DeepEqual(msg, tt.want) {
+				t.Errorf("parseStringSequence(%q) = %d, %#v, want %d, %#v", tt.in, n, msg, tt.n, tt.want)
+			}
+		})
+	}
+
+	// An unterminated sequence is dropped once it reaches the size limit
+	long := append([]byte("\x1b]52;c;"), make([]byte, maxStringSequenceLen)...)
+	if n, msg := parseStringSequence(long); n != len(long) || msg != nil {
+		t.Errorf("oversized sequence = %d, %v, want %d, nil", n, msg, len(long))
+	}
+}
+
+func TestParseXColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want CellColor
+		ok   bool
+	}{
+		{"rgb:ffff/8080/0000", RGBColor(255, 128, 0), true},
+		{"rgb:ff/80/00", RGBColor(255, 128, 0), true},
+		{"rgb:f/8/0", RGBColor(255, 136, 0), true},
+		{"rgb:fff/800/000", RGBColor(255, 127, 0), true},
+		{"rgba:ffff/0000/0000/ffff", RGBColor(255, 0, 0), true},
+		{"#102030", RGBColor(0x10, 0x20, 0x30), true},
+		{"#1020", CellColor{}, false},
+		{"#10203g", CellColor{}, false},
+		{"rgb:ff/ff", CellColor{}, false},
+		{"rgb:ff//ff", CellColor{}, false},
+		{"rgb:fffff/0/0", CellColor{}, false},
+		{"rgb:zz/0/0", CellColor{}, false},
+		{"blue", CellColor{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseXColor(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseXColor(%q) = %+v, %t, want %+v, %t", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestBackgroundIsDark(t *testing.T) {
+	tests := []struct {
+		color CellColor
+		dark  bool
+	}{
+		{RGBColor(0, 0, 0), true},
+		{RGBColor(255, 255, 255), false},
+		{RGBColor(0x28, 0x2c, 0x34), true},
+		{RGBColor(0xfd, 0xf6, 0xe3), false},
+		{RGBColor(0, 0, 255), true},
+	}
+	for _, tt := range tests {
+		if got := (BackgroundColorMsg{Color: tt.color}).IsDark(); got != tt.dark {
+			t.Errorf("IsDark(%+v) = %t, want %t", tt.color, got, tt.dark)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package brew
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultMessageBuffer is the default capacity of the message channel and of
+// the subscription overflow queue
+const defaultMessageBuffer = 100
+
+// priorityBuffer is the capacity of the lane for input and quit messages
+const priorityBuffer = 64
+
+// OverflowPolicy controls what a subscription does when the program cannot
+// keep up with the messages it sends
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes send wait until there is room (the default)
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest never blocks and discards the oldest queued message
+	// when the queue is full
+	OverflowDropOldest
+	// OverflowCoalesce never blocks and replaces a queued message of the same
+	// type instead of queueing another one, dropping the oldest when full
+	OverflowCoalesce
+)
+
+// MessageStats reports counters of the program's message queues
+type MessageStats struct {
+	Sent      uint64 // messages passed to Send or a subscription's send
+	Blocked   uint64 // sends that had to wait for room in the channel
+	Dropped   uint64 // messages discarded by OverflowDropOldest or OverflowCoalesce
+	Coalesced uint64 // messages merged into an already queued message
+	Queued    int    // messages currently waiting in the overflow queue
+}
+
+// messageCounters holds the counters behind MessageStats
+type messageCounters struct {
+	sent      atomic.Uint64
+	blocked   atomic.Uint64
+	dropped   atomic.Uint64
+	coalesced atomic.Uint64
+}
+
+// isPriorityMsg reports whether msg skips ahead of bulk data. Input and quit
+// messages must never wait behind a chatty subscription.
+func isPriorityMsg(msg Msg) bool {
+	switch msg.(type) {
+	case tea.KeyMsg, KeyEventMsg, tea.MouseMsg, tea.FocusMsg, tea.BlurMsg,
+		tea.QuitMsg, QuitMsg, tea.WindowSizeMsg, windowSizeMsg:
+		return true
+	}
+	return false
+}
+
+// overflowQueue is a bounded queue for subscriptions that must not block
+type overflowQueue struct {
+	mu       sync.Mutex
+	msgs     []Msg
+	capacity int
+	ready    chan struct{}
+	counters *messageCounters
+}
+
+// newOverflowQueue creates a queue holding at most capacity messages
+func newOverflowQueue(capacity int, counters *messageCounters) *overflowQueue {
+	return &overflowQueue{
+		capacity: max(capacity, 1),
+		ready:    make(chan struct{}, 1),
+		counters: counters,
+	}
+}
+
+// push queues msg according to policy without blocking
+func (q *overflowQueue) push(msg Msg, policy OverflowPolicy) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// Merge into the newest queued message when it supports coalescing
+	if n := len(q.msgs); n > 0 {
+		if c, ok := q.msgs[n-1].(Coalescer); ok {
+			if merged, ok := c.Coalesce(msg); ok {
+				q.msgs[n-1] = merged
+				q.counters.coalesced.Add(1)
+				return
+			}
+		}
+	}
+
+	if policy == OverflowCoalesce {
+		t := reflect.TypeOf(msg)
+		for i, queued := range q.msgs {
+			if reflect.TypeOf(queued) == t {
+				q.msgs[i] = msg
+				q.counters.coalesced.Add(1)
+				return
+			}
+		}
+	}
+
+	if len(q.msgs) >= q.capacity {
+		q.msgs[0] = nil
+		q.msgs = q.msgs[1:]
+		q.counters.dropped.Add(1)
+	}
+	q.msgs = append(q.msgs, msg)
+	q.signal()
+}
+
+// pop removes the oldest message
+func (q *overflowQueue) pop() (Msg, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.msgs) == 0 {
+		return nil, false
+	}
+	msg := q.msgs[0]
+	q.msgs[0] = nil
+	q.msgs = q.msgs[1:]
+	if len(q.msgs) > 0 {
+		q.signal()
+	}
+	return msg, true
+}
+
+// len returns the number of queued messages
+func (q *overflowQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.msgs)
+}
+
+// signal wakes the program loop, the caller must hold the lock
+func (q *overflowQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// nextMsg returns the next message to dispatch, preferring the priority lane.
+// It returns false once the program context is done.
+func (p *Program) nextMsg() (Msg, bool) {
+	if len(p.pending) > 0 {
+		msg := p.pending[0]
+		p.pending = p.pending[1:]
+		return msg, true
+	}
+
+	for {
+		select {
+		case msg := <-p.priority:
+			return msg, true
+		default:
+		}
+
+		select {
+		case msg := <-p.priority:
+			return msg, true
+		case msg := <-p.msgChan:
+			return msg, true
+		case <-p.queue.ready:
+			if msg, ok := p.queue.pop(); ok {
+				return msg, true
+			}
+		case <-p.ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+// subscriptionSend returns the send function handed to a subscription
+func (p *Program) subscriptionSend(policy OverflowPolicy) func(Msg) {
+	if policy == OverflowBlock {
+		return p.Send
+	}
+	return func(msg Msg) {
+		if isPriorityMsg(msg) {
+			p.Send(msg)
+			return
+		}
+		p.counters.sent.Add(1)
+		p.queue.push(msg, policy)
+	}
+}
+
+// Stats returns the message queue counters, useful when debugging a program
+// that falls behind its subscriptions
+func (p *Program) Stats() MessageStats {
+	return MessageStats{
+		Sent:      p.counters.sent.Load(),
+		Blocked:   p.counters.blocked.Load(),
+		Dropped:   p.counters.dropped.Load(),
+		Coalesced: p.counters.coalesced.Load(),
+		Queued:    p.queue.len(),
+	}
+}
@@ -0,0 +1,171 @@
+package brew
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// appendMsg absorbs the appendMsgs queued behind it
+type appendMsg string
+
+func (m appendMsg) Coalesce(next Msg) (Msg, bool) {
+	if n, ok := next.(appendMsg); ok {
+		return m + n, true
+	}
+	return m, false
+}
+
+// otherMsg is a plain bulk message
+type otherMsg int
+
+func TestOverflowQueue(t *testing.T) {
+	tests := []struct {
+		name      string
+		capacity  int
+		policy    OverflowPolicy
+		push      []Msg
+		want      []Msg
+		dropped   uint64
+		coalesced uint64
+	}{
+		{
+			name:     "in order",
+			capacity: 4,
+			policy:   OverflowDropOldest,
+			push:     []Msg{otherMsg(1), otherMsg(2), otherMsg(3)},
+			want:     []Msg{otherMsg(1), otherMsg(2), otherMsg(3)},
+		},
+		{
+			name:     "drop oldest when full",
+			capacity: 2,
+			policy:   OverflowDropOldest,
+			push:     []Msg{otherMsg(1), otherMsg(2), otherMsg(3), otherMsg(4)},
+			want:     []Msg{otherMsg(3), otherMsg(4)},
+			dropped:  2,
+		},
+		{
+			name:      "coalesce replaces a queued message of the same type",
+			capacity:  4,
+			policy:    OverflowCoalesce,
+			push:      []Msg{otherMsg(1), "text", otherMsg(2)},
+			want:      []Msg{otherMsg(2), "text"},
+			coalesced: 1,
+		},
+		{
+			name:     "coalesce drops the oldest when full",
+			capacity: 2,
+			policy:   OverflowCoalesce,
+			push:     []Msg{otherMsg(1), "text", 1.5},
+			want:     []Msg{"text", 1.5},
+			dropped:  1,
+		},
+		{
+			name:      "coalescers merge into the newest message",
+			capacity:  4,
+			policy:    OverflowDropOldest,
+			push:      []Msg{appendMsg("a"), appendMsg("b"), otherMsg(1), appendMsg("c")},
+			want:      []Msg{appendMsg("ab"), otherMsg(1), appendMsg("c")},
+			coalesced: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counters := &messageCounters{}
+			q := newOverflowQueue(tt.capacity, counters)
+			for _, msg := range tt.push {
+				q.push(msg, tt.policy)
+			}
+			if got := q.len(); got != len(tt.want) {
+				t.Errorf("len() = %d, want %d", got, len(tt.want))
+			}
+			var got []Msg
+			for msg, ok := q.pop(); ok; msg, ok = q.pop() {
+				got = append(got, msg)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("popped %v, want %v", got, tt.want)
+			}
+			if d, c := counters.dropped.Load(), counters.coalesced.Load(); d != tt.dropped || c != tt.coalesced {
+				t.Errorf("dropped %d, coalesced %d, want %d, %d", d, c, tt.dropped, tt.coalesced)
+			}
+		})
+	}
+}
+
+func TestNextMsgOrder(t *testing.T) {
+	p := NewProgram(nil)
+	defer p.cancel()
+
+	p.subscriptionSend(OverflowDropOldest)(otherMsg(1))
+	p.Send(otherMsg(2))
+	p.Send(tea.KeyMsg{Type: tea.KeyEnter})
+	p.Send(tea.WindowSizeMsg{Width: 10, Height: 5})
+	p.pending = append(p.pending, CapabilitiesMsg{})
+
+	// Pending messages first, then the priority lane in order, then bulk
+	var got []Msg
+	for range 5 {
+		msg, ok := p.nextMsg()
+		if !ok {
+			t.Fatal("nextMsg stopped")
+		}
+		got = append(got, msg)
+	}
+	head := []Msg{CapabilitiesMsg{}, tea.KeyMsg{Type: tea.KeyEnter}, tea.WindowSizeMsg{Width: 10, Height: 5}}
+	if !reflect.DeepEqual(got[:3], head) {
+		t.Errorf("first messages = %v, want %v", got[:3], head)
+	}
+	if !reflect.DeepEqual(got[3:], []Msg{otherMsg(1), otherMsg(2)}) && !reflect.DeepEqual(got[3:], []Msg{otherMsg(2), otherMsg(1)}) {
+		t.Errorf("bulk messages = %v", got[3:])
+	}
+
+	p.cancel()
+	if _, ok := p.nextMsg(); ok {
+		t.Error("nextMsg returned a message after the program finished")
+	}
+}
+
+func TestMessageStats(t *testing.T) {
+	p := NewProgram(nil).WithMessageBuffer(1)
+	defer p.cancel()
+
+	// A subscription that must not block drops the oldest messages
+	send := p.subscriptionSend(OverflowDropOldest)
+	for i := range 3 {
+		send(otherMsg(i))
+	}
+
+	// A blocking send waits until the full channel has room
+	p.Send(otherMsg(10))
+	sent := make(chan struct{})
+	go func() {
+		p.Send(otherMsg(11))
+		close(sent)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for p.Stats().Blocked == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	<-p.msgChan
+	<-sent
+
+	want := MessageStats{Sent: 5, Blocked: 1, Dropped: 2, Queued: 1}
+	if got := p.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestWithMessageBuffer(t *testing.T) {
+	for _, size := range []int{-1, 0, 1, 50} {
+		p := NewProgram(nil).WithMessageBuffer(size)
+		if got, want := cap(p.msgChan), max(size, 1); got != want {
+			t.Errorf("WithMessageBuffer(%d): channel capacity %d, want %d", size, got, want)
+		}
+		if got, want := p.queue.capacity, max(size, 1); got != want {
+			t.Errorf("WithMessageBuffer(%d): queue capacity %d, want %d", size, got, want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package brew
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// castRecorder writes terminal output or input to an asciicast v2 file
+// (https://docs.asciinema.org/manual/asciicast/v2/)
+type castRecorder struct {
+	mu       sync.Mutex
+	file     *os.File
+	start    time.Time
+	lastSize Size
+	partial  map[string][]byte
+	closed   bool
+}
+
+// castHeader is the first line of an asciicast v2 file
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// newCastRecorder creates the cast file and writes its header
+func newCastRecorder(path string, size Size) (*castRecorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &castRecorder{
+		file:     file,
+		start:    time.Now(),
+		lastSize: size,
+		partial:  make(map[string][]byte),
+	}
+	header, err := json.Marshal(castHeader{
+		Version:   2,
+		Width:     size.Width,
+		Height:    size.Height,
+		Timestamp: r.start.Unix(),
+		Env: map[string]string{
+			"TERM":  os.Getenv("TERM"),
+			"SHELL": os.Getenv("SHELL"),
+		},
+	})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(file, "%s\n", header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write records terminal output as an "o" event
+func (r *castRecorder) Write(p []byte) (int, error) {
+	return r.write("o", p)
+}
+
+// castInput records everything written to it as "i" events
+type castInput struct {
+	recorder *castRecorder
+}
+
+// Write records terminal input as an "i" event
+func (c castInput) Write(p []byte) (int, error) {
+	return c.recorder.write("i", p)
+}
+
+// write records p as an event of the given kind
+func (r *castRecorder) write(kind string, p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+
+	// Events must hold valid UTF-8, so hold back a rune split across writes
+	data := append(r.partial[kind], p...)
+	cut := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	r.partial[kind] = append([]byte(nil), data[cut:]...)
+
+	if cut > 0 {
+		if err := r.event(kind, string(data[:cut])); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// resize records an "r" event when the terminal size changed
+func (r *castRecorder) resize(size Size) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed || size == r.lastSize {
+		return nil
+	}
+	r.lastSize = size
+	return r.event("r", fmt.Sprintf("%dx%d", size.Width, size.Height))
+}
+
+// event appends a single event line, the caller must hold the lock
+func (r *castRecorder) event(kind, data string) error {
+	line, err := json.Marshal([]any{time.Since(r.start).Seconds(), kind, data})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(r.file, "%s\n", line)
+	return err
+}
+
+// Close flushes any held back output and closes the file
+func (r *castRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for kind, data := range r.partial {
+		if len(data) > 0 {
+			r.event(kind, string(data))
+		}
+	}
+	r.closed = true
+	return r.file.Close()
+}
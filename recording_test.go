@@ -0,0 +1,144 @@
+package brew
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// castWrite is a single write to a recorder, of kind "o" or "i"
+type castWrite struct {
+	kind string
+	data string
+}
+
+// readCast returns the header and the kinds and data of the events in a cast
+// file
+func readCast(t *testing.T, path string) (castHeader, []castWrite) {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	var header castHeader
+	if !scanner.Scan() || json.Unmarshal(scanner.Bytes(), &header) != nil {
+		t.Fatalf("bad cast header %q", scanner.Text())
+	}
+	var events []castWrite
+	for scanner.Scan() {
+		var event castEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("bad cast event %q: %v", scanner.Text(), err)
+		}
+		events = append(events, castWrite{event.Kind, event.Data})
+	}
+	return header, events
+}
+
+func TestCastRecorderUTF8(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []castWrite
+		want   []castWrite
+	}{
+		{
+			name:   "whole runes",
+			writes: []castWrite{{"o", "héllo"}},
+			want:   []castWrite{{"o", "héllo"}},
+		},
+		{
+			name:   "two byte rune split",
+			writes: []castWrite{{"o", "h\xc3"}, {"o", "\xa9llo"}},
+			want:   []castWrite{{"o", "h"}, {"o", "éllo"}},
+		},
+		{
+			name:   "rune split over three writes",
+			writes: []castWrite{{"o", "\xe6"}, {"o", "\x97"}, {"o", "\xa5!"}},
+			want:   []castWrite{{"o", "日!"}},
+		},
+		{
+			name:   "four byte rune split",
+			writes: []castWrite{{"o", "a\xf0"}, {"o", "\x9f\x98\x80"}},
+			want:   []castWrite{{"o", "a"}, {"o", "😀"}},
+		},
+		{
+			name:   "input and output are held separately",
+			writes: []castWrite{{"o", "\xc3"}, {"i", "a"}, {"o", "\xa9"}},
+			want:   []castWrite{{"i", "a"}, {"o", "é"}},
+		},
+		{
+			name:   "invalid bytes are not held",
+			writes: []castWrite{{"o", "a\x80"}},
+			want:   []castWrite{{"o", "a�"}},
+		},
+		{
+			name:   "incomplete rune flushed on close",
+			writes: []castWrite{{"o", "a\xe6\x97"}},
+			want:   []castWrite{{"o", "a"}, {"o", "��"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "session.cast")
+			r, err := newCastRecorder(path, Size{Width: 80, Height: 24})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range tt.writes {
+				var n int
+				var err error
+				if w.kind == "i" {
+					n, err = castInput{recorder: r}.Write([]byte(w.data))
+				} else {
+					n, err = r.Write([]byte(w.data))
+				}
+				if n != len(w.data) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v", w.data, n, err)
+				}
+			}
+			if err := r.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, got := readCast(t, path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCastRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.cast")
+	r, err := newCastRecorder(path, Size{Width: 80, Height: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.resize(Size{Width: 80, Height: 24})
+	r.Write([]byte("a"))
+	r.resize(Size{Width: 100, Height: 30})
+	r.resize(Size{Width: 100, Height: 30})
+	r.Close()
+
+	if _, err := r.Write([]byte("late")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write after Close = %v, want os.ErrClosed", err)
+	}
+	if err := r.resize(Size{Width: 1, Height: 1}); err != nil {
+		t.Errorf("resize after Close = %v", err)
+	}
+
+	header, events := readCast(t, path)
+	if header.Version != 2 || header.Width != 80 || header.Height != 24 {
+		t.Errorf("header = %+v", header)
+	}
+	if want := []castWrite{{"o", "a"}, {"r", "100x30"}}; !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+}
@@ -0,0 +1,143 @@
+package brew
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// castEvent is a single event line of an asciicast v2 file
+type castEvent struct {
+	Time float64
+	Kind string
+	Data string
+}
+
+// UnmarshalJSON decodes the [time, kind, data] array form
+func (e *castEvent) UnmarshalJSON(b []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 3 {
+		return fmt.Errorf("cast event has %d fields, want 3", len(raw))
+	}
+	if err := json.Unmarshal(raw[0], &e.Time); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw[1], &e.Kind); err != nil {
+		return err
+	}
+	return json.Unmarshal(raw[2], &e.Data)
+}
+
+// inputReplay feeds input recorded with WithInputRecording back into a
+// program, honouring the original timing and terminal size changes
+type inputReplay struct {
+	ctx      context.Context
+	events   []castEvent
+	start    time.Time
+	pending  []byte
+	onResize func()
+
+	mu   sync.Mutex
+	size Size
+}
+
+// loadInputReplay reads a recorded input session
+func loadInputReplay(path string) (*inputReplay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: missing asciicast header", path)
+	}
+	var header castHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if header.Version != 2 {
+		return nil, fmt.Errorf("%s: unsupported asciicast version %d", path, header.Version)
+	}
+
+	r := &inputReplay{size: Size{Width: header.Width, Height: header.Height}}
+	for line := 2; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event castEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if event.Kind == "i" || event.Kind == "r" {
+			r.events = append(r.events, event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Size returns the terminal size at the current point of the replay
+func (r *inputReplay) Size() (Size, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.size, nil
+}
+
+// Read returns the recorded input, waiting until each event is due
+func (r *inputReplay) Read(p []byte) (int, error) {
+	if r.start.IsZero() {
+		r.start = time.Now()
+	}
+
+	for len(r.pending) == 0 {
+		if len(r.events) == 0 {
+			return 0, io.EOF
+		}
+		event := r.events[0]
+		r.events = r.events[1:]
+
+		due := r.start.Add(time.Duration(event.Time * float64(time.Second)))
+		select {
+		case <-time.After(time.Until(due)):
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		}
+
+		switch event.Kind {
+		case "i":
+			r.pending = []byte(event.Data)
+		case "r":
+			var size Size
+			if _, err := fmt.Sscanf(event.Data, "%dx%d", &size.Width, &size.Height); err != nil {
+				continue
+			}
+			r.mu.Lock()
+			r.size = size
+			r.mu.Unlock()
+			if r.onResize != nil {
+				r.onResize()
+			}
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
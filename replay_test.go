@@ -0,0 +1,149 @@
+package brew
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeCast writes a cast file with the given lines and returns its path
+func writeCast(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "session.cast")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const testCastHeader = `{"version": 2, "width": 80, "height": 24}`
+
+func TestLoadInputReplay(t *testing.T) {
+	tests := []struct {
+		name   string
+		lines  []string
+		events []castEvent
+		err    string
+	}{
+		{
+			name:  "input and resize events",
+			lines: []string{testCastHeader, `[0.1, "o", "ignored"]`, `[0.2, "i", "a"]`, ``, `[0.3, "r", "100x30"]`, `[0.4, "m", "marker"]`},
+			events: []castEvent{
+				{Time: 0.2, Kind: "i", Data: "a"},
+				{Time: 0.3, Kind: "r", Data: "100x30"},
+			},
+		},
+		{
+			name:  "header only",
+			lines: []string{testCastHeader},
+		},
+		{
+			name: "empty file",
+			err:  "missing asciicast header",
+		},
+		{
+			name:  "bad header",
+			lines: []string{`[0.1, "i", "a"]`},
+			err:   "cannot unmarshal",
+		},
+		{
+			name:  "unsupported version",
+			lines: []string{`{"version": 1, "width": 80, "height": 24}`},
+			err:   "unsupported asciicast version 1",
+		},
+		{
+			name:  "bad event",
+			lines: []string{testCastHeader, `[0.1, "i", "a"]`, `{"time": 1}`},
+			err:   "session.cast:3:",
+		},
+		{
+			name:  "missing event field",
+			lines: []string{testCastHeader, `[0.1, "i"]`},
+			err:   "cast event has 2 fields, want 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := loadInputReplay(writeCast(t, tt.lines...))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(r.events, tt.events) {
+				t.Errorf("events = %+v, want %+v", r.events, tt.events)
+			}
+			if size, _ := r.Size(); size != (Size{Width: 80, Height: 24}) {
+				t.Errorf("Size() = %+v, want 80x24", size)
+			}
+		})
+	}
+
+	if _, err := loadInputReplay(filepath.Join(t.TempDir(), "missing.cast")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file error = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestInputReplayRead(t *testing.T) {
+	r := &inputReplay{
+		ctx:  context.Background(),
+		size: Size{Width: 80, Height: 24},
+		events: []castEvent{
+			{Time: 0, Kind: "i", Data: "abc"},
+			{Time: 0.02, Kind: "r", Data: "100x30"},
+			{Time: 0.02, Kind: "r", Data: "bad"},
+			{Time: 0.02, Kind: "i", Data: "d"},
+		},
+	}
+	resized := 0
+	r.onResize = func() { resized++ }
+
+	// Input larger than the buffer is returned over several reads
+	buf := make([]byte, 2)
+	start := time.Now()
+	var got []string
+	for {
+		n, err := r.Read(buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(buf[:n]))
+	}
+	if want := []string{"ab", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("read %q, want %q", got, want)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("replay took %v, want the recorded 20ms", elapsed)
+	}
+	if size, _ := r.Size(); size != (Size{Width: 100, Height: 30}) {
+		t.Errorf("Size() = %+v, want 100x30", size)
+	}
+	if resized != 1 {
+		t.Errorf("onResize called %d times, want 1", resized)
+	}
+}
+
+func TestInputReplayCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &inputReplay{
+		ctx:    ctx,
+		events: []castEvent{{Time: 60, Kind: "i", Data: "a"}},
+	}
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if n, err := r.Read(make([]byte, 8)); n != 0 || !errors.Is(err, context.Canceled) {
+		t.Errorf("Read = %d, %v, want 0, context.Canceled", n, err)
+	}
+}
@@ -0,0 +1,21 @@
+package brew
+
+import "context"
+
+// FromChannel creates a subscription that converts every value received on
+// ch into a message with toMsg. It stops when ch is closed.
+func FromChannel[T any](ch <-chan T, toMsg func(T) Msg) Sub {
+	return func(ctx context.Context, send func(Msg)) {
+		for {
+			select {
+			case v, ok := <-ch:
+				if !ok {
+					return
+				}
+				send(toMsg(v))
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
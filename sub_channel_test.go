@@ -0,0 +1,56 @@
+package brew
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// runSub runs sub until it returns and collects the messages it sent
+func runSub(ctx context.Context, sub Sub) []Msg {
+	var mu sync.Mutex
+	var msgs []Msg
+	sub(ctx, func(msg Msg) {
+		mu.Lock()
+		msgs = append(msgs, msg)
+		mu.Unlock()
+	})
+	return msgs
+}
+
+func TestFromChannel(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int
+		want   []Msg
+	}{
+		{"closed without values", nil, nil},
+		{"values in order", []int{1, 2, 3}, []Msg{otherMsg(1), otherMsg(2), otherMsg(3)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := make(chan int, len(tt.values))
+			for _, v := range tt.values {
+				ch <- v
+			}
+			close(ch)
+
+			got := runSub(context.Background(), FromChannel(ch, func(v int) Msg { return otherMsg(v) }))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sent %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromChannelCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The channel stays open, so only the cancellation ends the subscription
+	ch := make(chan int)
+	if got := runSub(ctx, FromChannel(ch, func(v int) Msg { return v })); len(got) != 0 {
+		t.Errorf("sent %v after cancel", got)
+	}
+}
@@ -0,0 +1,42 @@
+package brew
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// ConnLineMsg is sent by ConnLines for every line read from the connection
+type ConnLineMsg struct {
+	Addr string
+	Line string
+}
+
+// ConnClosedMsg is sent by ConnLines when the connection reached EOF (Err is
+// nil) or failed
+type ConnClosedMsg struct {
+	Addr string
+	Err  error
+}
+
+// ConnLines creates a subscription that sends a ConnLineMsg for every line
+// read from conn. Cancelling the subscription interrupts the pending read but
+// leaves closing the connection to the caller.
+func ConnLines(conn net.Conn) Sub {
+	return func(ctx context.Context, send func(Msg)) {
+		addr := conn.RemoteAddr().String()
+
+		stop := context.AfterFunc(ctx, func() {
+			conn.SetReadDeadline(time.Now())
+		})
+		defer stop()
+
+		err := scanLines(conn, func(line string) {
+			send(ConnLineMsg{Addr: addr, Line: line})
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		send(ConnClosedMsg{Addr: addr, Err: err})
+	}
+}
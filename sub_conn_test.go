@@ -0,0 +1,58 @@
+package brew
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConnLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Msg
+	}{
+		{"empty", "", []Msg{ConnClosedMsg{Addr: "pipe"}}},
+		{"lines", "a\nb\r\n", []Msg{
+			ConnLineMsg{Addr: "pipe", Line: "a"},
+			ConnLineMsg{Addr: "pipe", Line: "b"},
+			ConnClosedMsg{Addr: "pipe"},
+		}},
+		{"final line without newline", "a\n\nc", []Msg{
+			ConnLineMsg{Addr: "pipe", Line: "a"},
+			ConnLineMsg{Addr: "pipe", Line: ""},
+			ConnLineMsg{Addr: "pipe", Line: "c"},
+			ConnClosedMsg{Addr: "pipe"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer server.Close()
+			go func() {
+				client.Write([]byte(tt.input))
+				client.Close()
+			}()
+
+			if got := runSub(context.Background(), ConnLines(server)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sent %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnLinesCancel(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	// The pending read is interrupted and no ConnClosedMsg follows
+	if got := runSub(ctx, ConnLines(server)); len(got) != 0 {
+		t.Errorf("sent %v after cancel", got)
+	}
+}
@@ -0,0 +1,171 @@
+package brew
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// FileLineMsg is sent by FollowFile for every line appended to the file
+type FileLineMsg struct {
+	Path string
+	Line string
+}
+
+// FileErrorMsg reports a failure while following a file. Following continues
+// and the read is retried on the next poll.
+type FileErrorMsg struct {
+	Path string
+	Err  error
+}
+
+// followPollInterval is how often a followed file is checked for new data
+const followPollInterval = 250 * time.Millisecond
+
+// FollowFile creates a subscription that sends a FileLineMsg for each line
+// written to path, like tail -F. When fromStart is false only lines appended
+// after the subscription started are sent. The file may not exist yet, and
+// is reopened when it is rotated (replaced by a new file) or truncated.
+func FollowFile(path string, fromStart bool) Sub {
+	return func(ctx context.Context, send func(Msg)) {
+		f := &fileFollower{path: path, send: send, fromStart: fromStart}
+		defer f.close()
+
+		ticker := time.NewTicker(followPollInterval)
+		defer ticker.Stop()
+
+		for {
+			f.poll()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// fileFollower holds the state of a FollowFile subscription
+type fileFollower struct {
+	path      string
+	send      func(Msg)
+	fromStart bool
+
+	file    *os.File
+	info    os.FileInfo
+	reader  *bufio.Reader
+	offset  int64
+	partial string
+	lastErr string
+}
+
+// poll sends any new lines, handling rotation and truncation first
+func (f *fileFollower) poll() {
+	if f.file == nil && !f.open() {
+		return
+	}
+
+	info, err := os.Stat(f.path)
+	switch {
+	case err != nil:
+		// The file was moved away; keep reading it until a new one appears
+	case !os.SameFile(info, f.info):
+		// Rotated: finish the old file, then continue with the new one from
+		// its beginning
+		f.readLines()
+		f.close()
+		f.fromStart = true
+		if !f.open() {
+			return
+		}
+	case info.Size() < f.offset:
+		// Truncated in place: start over
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			f.report(err)
+			return
+		}
+		f.reader.Reset(f.file)
+		f.offset = 0
+		f.partial = ""
+	}
+
+	f.readLines()
+}
+
+// open opens the file, positioning at its end unless reading from the start
+func (f *fileFollower) open() bool {
+	file, err := os.Open(f.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			f.report(err)
+		}
+		// A file that shows up later is read in full
+		f.fromStart = true
+		return false
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		f.report(err)
+		return false
+	}
+
+	var offset int64
+	if !f.fromStart {
+		if offset, err = file.Seek(0, io.SeekEnd); err != nil {
+			file.Close()
+			f.report(err)
+			return false
+		}
+	}
+
+	f.file = file
+	f.info = info
+	f.reader = bufio.NewReader(file)
+	f.offset = offset
+	f.partial = ""
+	f.lastErr = ""
+	return true
+}
+
+// readLines sends every complete line available, keeping a trailing partial
+// line until the rest of it is written
+func (f *fileFollower) readLines() {
+	for {
+		chunk, err := f.reader.ReadString('\n')
+		f.offset += int64(len(chunk))
+		if err != nil {
+			f.partial += chunk
+			if err != io.EOF {
+				f.report(err)
+			}
+			return
+		}
+
+		line := strings.TrimRight(f.partial+chunk, "\r\n")
+		f.partial = ""
+		f.send(FileLineMsg{Path: f.path, Line: line})
+	}
+}
+
+// report sends a FileErrorMsg, suppressing repeats of the same error
+func (f *fileFollower) report(err error) {
+	if err.Error() == f.lastErr {
+		return
+	}
+	f.lastErr = err.Error()
+	f.send(FileErrorMsg{Path: f.path, Err: err})
+}
+
+// close releases the current file
+func (f *fileFollower) close() {
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+}
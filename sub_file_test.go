@@ -0,0 +1,157 @@
+package brew
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// appendFile appends data to the file at path, creating it when needed
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileFollower(t *testing.T) {
+	// Each step changes the file, then the follower polls once and sends the
+	// lines wanted
+	type step struct {
+		change func(t *testing.T, path string)
+		want   []string
+	}
+	write := func(data string) func(*testing.T, string) {
+		return func(t *testing.T, path string) { appendFile(t, path, data) }
+	}
+	truncate := func(data string) func(*testing.T, string) {
+		return func(t *testing.T, path string) {
+			if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	rotate := func(old, data string) func(*testing.T, string) {
+		return func(t *testing.T, path string) {
+			appendFile(t, path, old)
+			if err := os.Rename(path, path+".1"); err != nil {
+				t.Fatal(err)
+			}
+			appendFile(t, path, data)
+		}
+	}
+	remove := func(t *testing.T, path string) {
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	nothing := func(*testing.T, string) {}
+
+	tests := []struct {
+		name      string
+		initial   string // contents before following, no file when empty
+		fromStart bool
+		steps     []step
+	}{
+		{
+			name:    "only new lines",
+			initial: "old\n",
+			steps:   []step{{nothing, nil}, {write("new\n"), []string{"new"}}},
+		},
+		{
+			name:      "from the start",
+			initial:   "a\nb\n",
+			fromStart: true,
+			steps:     []step{{nothing, []string{"a", "b"}}, {write("c\n"), []string{"c"}}},
+		},
+		{
+			name:    "partial line waits for its end",
+			initial: "old\n",
+			steps:   []step{{nothing, nil}, {write("par"), nil}, {write("tial\r\n"), []string{"partial"}}},
+		},
+		{
+			name:  "file created later is read in full",
+			steps: []step{{nothing, nil}, {write("x\ny\n"), []string{"x", "y"}}},
+		},
+		{
+			name:      "truncated",
+			initial:   "a long line\n",
+			fromStart: true,
+			steps:     []step{{nothing, []string{"a long line"}}, {truncate("b\n"), []string{"b"}}},
+		},
+		{
+			name:    "rotated",
+			initial: "old\n",
+			steps:   []step{{nothing, nil}, {rotate("tail\n", "new\n"), []string{"tail", "new"}}, {write("more\n"), []string{"more"}}},
+		},
+		{
+			name:    "removed",
+			initial: "old\n",
+			steps:   []step{{nothing, nil}, {remove, nil}, {write("new\n"), []string{"new"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log")
+			if tt.initial != "" {
+				appendFile(t, path, tt.initial)
+			}
+
+			var sent []Msg
+			f := &fileFollower{path: path, send: func(msg Msg) { sent = append(sent, msg) }, fromStart: tt.fromStart}
+			defer f.close()
+
+			for i, s := range tt.steps {
+				s.change(t, path)
+				f.poll()
+				var want []Msg
+				for _, line := range s.want {
+					want = append(want, FileLineMsg{Path: path, Line: line})
+				}
+				if !reflect.DeepEqual(sent, want) {
+					t.Errorf("step %d sent %v, want %v", i, sent, want)
+				}
+				sent = nil
+			}
+		})
+	}
+}
+
+func TestFileFollowerReport(t *testing.T) {
+	var sent []Msg
+	f := &fileFollower{path: "app.log", send: func(msg Msg) { sent = append(sent, msg) }}
+
+	first, second := errors.New("first"), errors.New("second")
+	for _, err := range []error{first, first, second, first} {
+		f.report(err)
+	}
+	want := []Msg{
+		FileErrorMsg{Path: "app.log", Err: first},
+		FileErrorMsg{Path: "app.log", Err: second},
+		FileErrorMsg{Path: "app.log", Err: first},
+	}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent %v, want %v", sent, want)
+	}
+}
+
+func TestFollowFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "a\n")
+
+	// The file is polled once before the cancellation is noticed
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got := runSub(ctx, FollowFile(path, true))
+	if want := []Msg{FileLineMsg{Path: path, Line: "a"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,94 @@
+package brew
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProcessLineMsg is sent by ProcessOutput for every line the process writes
+type ProcessLineMsg struct {
+	Name   string
+	Stderr bool
+	Line   string
+}
+
+// ProcessExitMsg is sent by ProcessOutput once the process has exited on its
+// own. Err is nil when it exited successfully.
+type ProcessExitMsg struct {
+	Name     string
+	ExitCode int
+	Err      error
+}
+
+// processWaitDelay bounds how long a cancelled process may keep its pipes open
+const processWaitDelay = 2 * time.Second
+
+// ProcessOutput creates a subscription that starts the named program and
+// sends a ProcessLineMsg for each line it writes to stdout or stderr,
+// followed by a ProcessExitMsg. Cancelling the subscription kills the process.
+func ProcessOutput(name string, args ...string) Sub {
+	return func(ctx context.Context, send func(Msg)) {
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.WaitDelay = processWaitDelay
+
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			send(ProcessExitMsg{Name: name, ExitCode: -1, Err: err})
+			return
+		}
+		stderr, err := cmd.StderrPipe()
+		if err != nil {
+			send(ProcessExitMsg{Name: name, ExitCode: -1, Err: err})
+			return
+		}
+		if err := cmd.Start(); err != nil {
+			send(ProcessExitMsg{Name: name, ExitCode: -1, Err: err})
+			return
+		}
+
+		// Pipes must be drained before Wait closes them
+		var wg sync.WaitGroup
+		for _, pipe := range []struct {
+			r      io.Reader
+			stderr bool
+		}{{stdout, false}, {stderr, true}} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				scanLines(pipe.r, func(line string) {
+					send(ProcessLineMsg{Name: name, Stderr: pipe.stderr, Line: line})
+				})
+			}()
+		}
+		wg.Wait()
+
+		err = cmd.Wait()
+		if ctx.Err() != nil {
+			return
+		}
+		send(ProcessExitMsg{Name: name, ExitCode: cmd.ProcessState.ExitCode(), Err: err})
+	}
+}
+
+// scanLines calls fn for every line read from r, including a final line
+// without a trailing newline. It returns nil at EOF.
+func scanLines(r io.Reader, fn func(line string)) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" && (err == nil || err == io.EOF) {
+			fn(strings.TrimRight(line, "\r\n"))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package brew
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestScanLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"empty", "", nil},
+		{"lines", "a\nb\n", []string{"a", "b"}},
+		{"CRLF", "a\r\nb\r\n", []string{"a", "b"}},
+		{"blank lines", "\n\na\n", []string{"", "", "a"}},
+		{"final line without newline", "a\nb", []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := scanLines(strings.NewReader(tt.input), func(line string) { got = append(got, line) })
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scanLines = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	// A read error ends scanning and is returned, without a partial line
+	failure := errors.New("failed")
+	var got []string
+	err := scanLines(iotest.DataErrReader(iotest.ErrReader(failure)), func(line string) { got = append(got, line) })
+	if !errors.Is(err, failure) || len(got) != 0 {
+		t.Errorf("scanLines = %q, %v, want no lines and the read error", got, err)
+	}
+}
+
+func TestProcessOutput(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell available")
+	}
+
+	got := runSub(context.Background(), ProcessOutput("sh", "-c", "echo out; echo err >&2; exit 3"))
+	if len(got) != 3 {
+		t.Fatalf("sent %v, want two lines and the exit", got)
+	}
+	// Lines from stdout and stderr may arrive in either order
+	lines := []Msg{ProcessLineMsg{Name: "sh", Line: "out"}, ProcessLineMsg{Name: "sh", Stderr: true, Line: "err"}}
+	if !reflect.DeepEqual(got[:2], lines) && !reflect.DeepEqual(got[:2], []Msg{lines[1], lines[0]}) {
+		t.Errorf("lines = %v, want %v", got[:2], lines)
+	}
+	if exit, ok := got[2].(ProcessExitMsg); !ok || exit.ExitCode != 3 || exit.Err == nil {
+		t.Errorf("last message = %+v, want exit code 3 with an error", got[2])
+	}
+
+	got = runSub(context.Background(), ProcessOutput("sh", "-c", "true"))
+	if want := []Msg{ProcessExitMsg{Name: "sh"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("successful exit = %v, want %v", got, want)
+	}
+}
+
+func TestProcessOutputNotFound(t *testing.T) {
+	got := runSub(context.Background(), ProcessOutput("coldbrew-no-such-program"))
+	if len(got) != 1 {
+		t.Fatalf("sent %v, want one exit message", got)
+	}
+	if exit, ok := got[0].(ProcessExitMsg); !ok || exit.ExitCode != -1 || exit.Err == nil {
+		t.Errorf("sent %+v, want exit code -1 with an error", got[0])
+	}
+}
+
+func TestProcessOutputCancel(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("no sleep available")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	// The process is killed and no ProcessExitMsg follows
+	start := time.Now()
+	if got := runSub(ctx, ProcessOutput("sleep", "10")); len(got) != 0 {
+		t.Errorf("sent %v after cancel", got)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancelled process ran for %v", elapsed)
+	}
+}
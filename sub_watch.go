@@ -0,0 +1,61 @@
+package brew
+
+import (
+	"context"
+	"strings"
+)
+
+// WatchOp describes what happened to an entry of a watched directory
+type WatchOp uint32
+
+const (
+	WatchCreate WatchOp = 1 << iota
+	WatchWrite
+	WatchRemove
+	WatchRename
+	WatchChmod
+)
+
+// String returns the operations joined by "|"
+func (op WatchOp) String() string {
+	var names []string
+	for _, o := range []struct {
+		op   WatchOp
+		name string
+	}{
+		{WatchCreate, "create"},
+		{WatchWrite, "write"},
+		{WatchRemove, "remove"},
+		{WatchRename, "rename"},
+		{WatchChmod, "chmod"},
+	} {
+		if op&o.op != 0 {
+			names = append(names, o.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// WatchMsg is sent by WatchDir for every change to an entry of the directory
+type WatchMsg struct {
+	Dir  string
+	Name string
+	Op   WatchOp
+}
+
+// WatchErrorMsg reports that a directory watch failed and has stopped
+type WatchErrorMsg struct {
+	Dir string
+	Err error
+}
+
+// WatchDir creates a subscription that sends a WatchMsg whenever an entry of
+// dir is created, written, removed, renamed or has its attributes changed.
+// It uses inotify on Linux and falls back to polling elsewhere.
+func WatchDir(dir string) Sub {
+	return func(ctx context.Context, send func(Msg)) {
+		if err := watchDir(ctx, dir, send); err != nil && ctx.Err() == nil {
+			send(WatchErrorMsg{Dir: dir, Err: err})
+		}
+	}
+}
@@ -0,0 +1,82 @@
+//go:build linux
+
+package brew
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyMask selects the events reported by WatchDir
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ATTRIB |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// watchDir reports changes to dir using inotify until ctx is cancelled
+func watchDir(ctx context.Context, dir string, send func(Msg)) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return os.NewSyscallError("inotify_init1", err)
+	}
+	// A non-blocking descriptor is handled by the runtime poller, so closing
+	// the file interrupts a pending Read
+	file := os.NewFile(uintptr(fd), "inotify")
+	defer file.Close()
+
+	if _, err := syscall.InotifyAddWatch(fd, dir, inotifyMask); err != nil {
+		return os.NewSyscallError("inotify_add_watch", err)
+	}
+
+	stop := context.AfterFunc(ctx, func() { file.Close() })
+	defer stop()
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := file.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+int(event.Len)]), "\x00")
+			offset = nameStart + int(event.Len)
+
+			if event.Mask&(syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF|syscall.IN_IGNORED) != 0 {
+				return errors.New("watched directory was removed")
+			}
+			if op := inotifyOp(event.Mask); op != 0 {
+				send(WatchMsg{Dir: dir, Name: name, Op: op})
+			}
+		}
+	}
+}
+
+// inotifyOp converts an inotify event mask to a WatchOp
+func inotifyOp(mask uint32) WatchOp {
+	var op WatchOp
+	if mask&syscall.IN_CREATE != 0 {
+		op |= WatchCreate
+	}
+	if mask&(syscall.IN_MODIFY|syscall.IN_CLOSE_WRITE) != 0 {
+		op |= WatchWrite
+	}
+	if mask&syscall.IN_DELETE != 0 {
+		op |= WatchRemove
+	}
+	if mask&(syscall.IN_MOVED_FROM|syscall.IN_MOVED_TO) != 0 {
+		op |= WatchRename
+	}
+	if mask&syscall.IN_ATTRIB != 0 {
+		op |= WatchChmod
+	}
+	return op
+}
@@ -0,0 +1,31 @@
+//go:build linux
+
+package brew
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestInotifyOp(t *testing.T) {
+	tests := []struct {
+		mask uint32
+		want WatchOp
+	}{
+		{syscall.IN_CREATE, WatchCreate},
+		{syscall.IN_MODIFY, WatchWrite},
+		{syscall.IN_CLOSE_WRITE, WatchWrite},
+		{syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE, WatchWrite},
+		{syscall.IN_DELETE, WatchRemove},
+		{syscall.IN_MOVED_FROM, WatchRename},
+		{syscall.IN_MOVED_TO, WatchRename},
+		{syscall.IN_ATTRIB, WatchChmod},
+		{syscall.IN_CREATE | syscall.IN_ISDIR, WatchCreate},
+		{syscall.IN_ACCESS, 0},
+	}
+	for _, tt := range tests {
+		if got := inotifyOp(tt.mask); got != tt.want {
+			t.Errorf("inotifyOp(%#x) = %v, want %v", tt.mask, got, tt.want)
+		}
+	}
+}
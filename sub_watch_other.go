@@ -0,0 +1,74 @@
+//go:build !linux
+
+package brew
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// watchPollInterval is how often a directory is rescanned without inotify
+const watchPollInterval = time.Second
+
+// watchDir reports changes to dir by comparing periodic directory listings
+// until ctx is cancelled
+func watchDir(ctx context.Context, dir string, send func(Msg)) error {
+	prev, err := scanDir(dir)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		cur, err := scanDir(dir)
+		if err != nil {
+			return err
+		}
+
+		for name, info := range cur {
+			old, existed := prev[name]
+			switch {
+			case !existed:
+				send(WatchMsg{Dir: dir, Name: name, Op: WatchCreate})
+			case !info.ModTime().Equal(old.ModTime()) || info.Size() != old.Size():
+				send(WatchMsg{Dir: dir, Name: name, Op: WatchWrite})
+			case info.Mode() != old.Mode():
+				send(WatchMsg{Dir: dir, Name: name, Op: WatchChmod})
+			}
+		}
+		for name := range prev {
+			if _, exists := cur[name]; !exists {
+				send(WatchMsg{Dir: dir, Name: name, Op: WatchRemove})
+			}
+		}
+		prev = cur
+	}
+}
+
+// scanDir returns the file info of every entry in dir
+func scanDir(dir string) (map[string]os.FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make(map[string]os.FileInfo, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			// Removed between listing and stat
+			continue
+		}
+		infos[entry.Name()] = info
+	}
+	return infos, nil
+}
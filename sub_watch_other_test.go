@@ -0,0 +1,39 @@
+//go:build !linux
+
+package brew
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestScanDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := scanDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range infos {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	if want := []string{"a", "b", "sub"}; !slices.Equal(names, want) {
+		t.Errorf("scanDir listed %q, want %q", names, want)
+	}
+
+	if _, err := scanDir(filepath.Join(dir, "missing")); err == nil {
+		t.Error("scanDir of a missing directory succeeded")
+	}
+}
@@ -0,0 +1,77 @@
+package brew
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchOpString(t *testing.T) {
+	tests := []struct {
+		op   WatchOp
+		want string
+	}{
+		{0, ""},
+		{WatchCreate, "create"},
+		{WatchWrite | WatchChmod, "write|chmod"},
+		{WatchCreate | WatchWrite | WatchRemove | WatchRename | WatchChmod, "create|write|remove|rename|chmod"},
+	}
+	for _, tt := range tests {
+		if got := tt.op.String(); got != tt.want {
+			t.Errorf("WatchOp(%d).String() = %q, want %q", tt.op, got, tt.want)
+		}
+	}
+}
+
+func TestWatchDir(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	msgs := make(chan Msg, 64)
+	done := make(chan struct{})
+	go func() {
+		WatchDir(dir)(ctx, func(msg Msg) { msgs <- msg })
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Keep creating files until the watch, which starts asynchronously,
+	// reports one of them
+	deadline := time.After(5 * time.Second)
+	tick := time.NewTicker(50 * time.Millisecond)
+	defer tick.Stop()
+	for i := 0; ; i++ {
+		select {
+		case msg := <-msgs:
+			watch, ok := msg.(WatchMsg)
+			if !ok {
+				t.Fatalf("sent %+v, want a WatchMsg", msg)
+			}
+			if watch.Dir != dir || watch.Op&WatchCreate == 0 {
+				t.Errorf("sent %+v, want a create in %s", watch, dir)
+			}
+			return
+		case <-tick.C:
+			if err := os.WriteFile(filepath.Join(dir, "file"+string(rune('a'+i%26))), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		case <-deadline:
+			t.Fatal("no change reported")
+		}
+	}
+}
+
+func TestWatchDirMissing(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+	got := runSub(context.Background(), WatchDir(dir))
+	if len(got) != 1 {
+		t.Fatalf("sent %v, want one error", got)
+	}
+	if msg, ok := got[0].(WatchErrorMsg); !ok || msg.Dir != dir || msg.Err == nil {
+		t.Errorf("sent %+v, want a WatchErrorMsg for %s", got[0], dir)
+	}
+}
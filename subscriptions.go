@@ -0,0 +1,115 @@
+package brew
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Subscription is a Sub identified by a stable key
+type Subscription struct {
+	Key string
+	Sub Sub
+
+	// Overflow controls what happens when the program falls behind the
+	// messages this subscription sends
+	Overflow OverflowPolicy
+}
+
+// Keyed creates a subscription identified by key
+func Keyed(key string, sub Sub) Subscription {
+	return Subscription{Key: key, Sub: sub}
+}
+
+// KeyedModel extends tea.Model with subscriptions identified by key.
+//
+// KeyedSubscriptions is called after every update. Subscriptions whose key
+// was not returned before are started, keys that are no longer returned have
+// their subscription's context cancelled, and keys that are still present
+// keep running untouched. A subscription that returns on its own is not
+// restarted until its key has been dropped and returned again.
+type KeyedModel interface {
+	tea.Model
+
+	// KeyedSubscriptions returns the subscriptions that should be running
+	KeyedSubscriptions() []Subscription
+}
+
+// modelSubscriptions returns the subscriptions the model currently wants
+func (p *Program) modelSubscriptions() ([]Subscription, bool) {
+	switch model := p.model.(type) {
+	case KeyedModel:
+		return model.KeyedSubscriptions(), true
+	case ColdbrewModel:
+		subs := model.Subscriptions()
+		keyed := make([]Subscription, 0, len(subs))
+		seen := make(map[uintptr]int, len(subs))
+		for _, sub := range subs {
+			if sub == nil {
+				continue
+			}
+			keyed = append(keyed, Subscription{Key: subscriptionKey(sub, seen), Sub: sub})
+		}
+		return keyed, true
+	}
+	return nil, false
+}
+
+// subscriptionKey identifies an unkeyed subscription by its function and, for
+// closures of the same function, by how many were seen before it
+func subscriptionKey(sub Sub, seen map[uintptr]int) string {
+	fn := reflect.ValueOf(sub).Pointer()
+	n := seen[fn]
+	seen[fn] = n + 1
+	return fmt.Sprintf("%#x#%d", fn, n)
+}
+
+// syncSubscriptions starts subscriptions with new keys and cancels the ones
+// whose keys disappeared
+func (p *Program) syncSubscriptions() {
+	subs, ok := p.modelSubscriptions()
+	if !ok && len(p.subs) == 0 {
+		return
+	}
+
+	wanted := make(map[string]bool, len(subs))
+	for _, sub := range subs {
+		if sub.Sub == nil || wanted[sub.Key] {
+			continue
+		}
+		wanted[sub.Key] = true
+		if _, running := p.subs[sub.Key]; !running {
+			p.startSubscription(sub)
+		}
+	}
+
+	for key, cancel := range p.subs {
+		if !wanted[key] {
+			p.trace("sub cancel", "key", key)
+			cancel()
+			delete(p.subs, key)
+		}
+	}
+}
+
+// startSubscription runs a subscription in its own goroutine with a context
+// that is cancelled when the subscription is removed or the program exits.
+// Messages sent once the context is cancelled are dropped.
+func (p *Program) startSubscription(sub Subscription) {
+	ctx, cancel := context.WithCancel(p.ctx)
+	p.subs[sub.Key] = cancel
+
+	send := p.subscriptionSend(sub.Overflow)
+	go func() {
+		p.trace("sub start", "key", sub.Key)
+		sub.Sub(ctx, func(msg Msg) {
+			if ctx.Err() != nil {
+				return
+			}
+			send(msg)
+		})
+		p.trace("sub stop", "key", sub.Key)
+	}()
+}
@@ -0,0 +1,176 @@
+package brew
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// subRecorder creates subscriptions that record when they start and stop
+type subRecorder struct {
+	mu      sync.Mutex
+	events  []string
+	stopped chan string
+}
+
+func newSubRecorder() *subRecorder {
+	return &subRecorder{stopped: make(chan string, 16)}
+}
+
+func (r *subRecorder) sub(name string) Sub {
+	return func(ctx context.Context, send func(Msg)) {
+		r.mu.Lock()
+		r.events = append(r.events, "start "+name)
+		r.mu.Unlock()
+		<-ctx.Done()
+		r.stopped <- name
+	}
+}
+
+// waitStarted waits until n subscriptions were started and returns the events
+func (r *subRecorder) waitStarted(t *testing.T, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		r.mu.Lock()
+		events := slices.Clone(r.events)
+		r.mu.Unlock()
+		if len(events) >= n {
+			slices.Sort(events)
+			return events
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("started %q, want %d subscriptions", events, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitStopped waits for the subscription called name to stop
+func (r *subRecorder) waitStopped(t *testing.T, name string) {
+	t.Helper()
+	select {
+	case got := <-r.stopped:
+		if got != name {
+			t.Fatalf("stopped %q, want %q", got, name)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("%q was not stopped", name)
+	}
+}
+
+type keyedSubModel struct {
+	subs []Subscription
+}
+
+func (m keyedSubModel) Init() tea.Cmd                       { return nil }
+func (m keyedSubModel) Update(tea.Msg) (tea.Model, tea.Cmd) { return m, nil }
+func (m keyedSubModel) View() string                        { return "" }
+func (m keyedSubModel) KeyedSubscriptions() []Subscription  { return m.subs }
+
+type coldbrewSubModel struct {
+	subs []Sub
+}
+
+func (m coldbrewSubModel) Init() tea.Cmd                       { return nil }
+func (m coldbrewSubModel) Update(tea.Msg) (tea.Model, tea.Cmd) { return m, nil }
+func (m coldbrewSubModel) View() string                        { return "" }
+func (m coldbrewSubModel) Subscriptions() []Sub                { return m.subs }
+
+func TestSyncKeyedSubscriptions(t *testing.T) {
+	r := newSubRecorder()
+	p := NewProgram(keyedSubModel{subs: []Subscription{Keyed("a", r.sub("a")), Keyed("b", r.sub("b"))}})
+	defer p.cancel()
+
+	p.syncSubscriptions()
+	if got, want := r.waitStarted(t, 2), []string{"start a", "start b"}; !slices.Equal(got, want) {
+		t.Fatalf("events = %q, want %q", got, want)
+	}
+
+	// Reordering keeps both running, a removed key is cancelled and a new
+	// one started
+	p.model = keyedSubModel{subs: []Subscription{Keyed("b", r.sub("b")), Keyed("a", r.sub("a"))}}
+	p.syncSubscriptions()
+	p.model = keyedSubModel{subs: []Subscription{Keyed("b", r.sub("b")), Keyed("c", r.sub("c"))}}
+	p.syncSubscriptions()
+	r.waitStopped(t, "a")
+	if got, want := r.waitStarted(t, 3), []string{"start a", "start b", "start c"}; !slices.Equal(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+	if len(p.subs) != 2 {
+		t.Errorf("running %d subscriptions, want 2", len(p.subs))
+	}
+}
+
+// distinctSubs returns subscriptions of three different functions, as
+// ColdbrewModel subscriptions are told apart by their function
+func distinctSubs(r *subRecorder) (a, b, c Sub) {
+	a = func(ctx context.Context, send func(Msg)) { r.sub("a")(ctx, send) }
+	b = func(ctx context.Context, send func(Msg)) { r.sub("b")(ctx, send) }
+	c = func(ctx context.Context, send func(Msg)) { r.sub("c")(ctx, send) }
+	return a, b, c
+}
+
+func TestSyncColdbrewSubscriptions(t *testing.T) {
+	r := newSubRecorder()
+	a, b, c := distinctSubs(r)
+
+	p := NewProgram(coldbrewSubModel{subs: []Sub{a, b, c}})
+	defer p.cancel()
+	p.syncSubscriptions()
+	r.waitStarted(t, 3)
+
+	// Removing the first entry must not cancel another subscription or
+	// restart the ones that moved up
+	p.model = coldbrewSubModel{subs: []Sub{b, c}}
+	p.syncSubscriptions()
+	r.waitStopped(t, "a")
+	if got, want := r.waitStarted(t, 3), []string{"start a", "start b", "start c"}; !slices.Equal(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+	select {
+	case name := <-r.stopped:
+		t.Errorf("%q stopped as well", name)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestSubscriptionKey(t *testing.T) {
+	r := newSubRecorder()
+	seen := make(map[uintptr]int)
+	first, second := subscriptionKey(r.sub("a"), seen), subscriptionKey(r.sub("b"), seen)
+	if first == second {
+		t.Errorf("closures of one function share the key %q", first)
+	}
+	a, _, _ := distinctSubs(r)
+	if other := subscriptionKey(a, seen); other == first || other == second {
+		t.Errorf("different functions share the key %q", other)
+	}
+}
+
+func TestSubscriptionDropsMessagesAfterCancel(t *testing.T) {
+	sent := make(chan struct{})
+	late := func(ctx context.Context, send func(Msg)) {
+		<-ctx.Done()
+		send("late")
+		close(sent)
+	}
+	p := NewProgram(keyedSubModel{subs: []Subscription{Keyed("late", late)}})
+	defer p.cancel()
+	p.syncSubscriptions()
+
+	p.model = keyedSubModel{}
+	p.syncSubscriptions()
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("subscription was not cancelled")
+	}
+	if stats := p.Stats(); stats.Sent != 0 || len(p.msgChan) != 0 {
+		t.Errorf("message delivered after cancel, stats %+v", stats)
+	}
+}
@@ -1,7 +1,9 @@
 package brew
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strconv"
@@ -10,63 +12,427 @@ import (
 	"github.com/charmbracelet/x/term"
 )
 
+// OutputMode selects how the terminal writes frames
+type OutputMode int
+
+const (
+	// OutputAuto renders interactively when stdout is a terminal and falls
+	// back to OutputFinalFrame otherwise, e.g. in pipes and CI logs
+	OutputAuto OutputMode = iota
+	// OutputInteractive redraws frames in place using cursor movement
+	OutputInteractive
+	// OutputFinalFrame writes only committed output (see Println) while
+	// running and the last frame when the program exits
+	OutputFinalFrame
+	// OutputAppend writes every new or changed line of a frame as a new line,
+	// without any cursor movement
+	OutputAppend
+)
+
 // Terminal provides rendering utilities
 type Terminal struct {
+	out            io.Writer
+	sizeFunc       func() (Size, error)
+	isTTY          bool
+	mode           OutputMode
+	stripANSI      bool
+	caps           Capabilities
 	previousBuffer []string
 	lastSize       Size
-	renderStartRow int
+	renderStartRow int // 1-based row of the first frame line, may be < 1 once scrolled off
 	renderStartCol int
+	startKnown     bool
+	cursorRow      int       // frame line the cursor is on
+	cursor         *Position // requested cursor position within the frame
+	cursorHidden   bool      // HideCursor was called
+	cursorShown    bool      // cursor shown for the frame despite HideCursor
+	placed         *Position // cursor position requested for the last frame
+	progress       bool      // a progress indicator is shown (OSC 9;4)
+	cprPending     []int     // cursor line + 1 at the time of outstanding position queries
 	totalRendered  int
 	firstRender    bool
+	frame          *bytes.Buffer
+	lastRender     renderStats
+}
+
+// renderStats describes the output of the most recent RenderString call
+type renderStats struct {
+	Mode      string // "initial", "full", "diff" or "unchanged"
+	FirstLine int    // first line that was rewritten
+	Lines     int    // number of lines written
+	Bytes     int    // number of bytes written
 }
 
 func NewTerminal() *Terminal {
-	return &Terminal{firstRender: true}
+	return &Terminal{
+		out:         os.Stdout,
+		isTTY:       term.IsTerminal(os.Stdout.Fd()),
+		caps:        DetectCapabilities(),
+		firstRender: true,
+	}
+}
+
+// WithOutput sets the writer the terminal renders to (defaults to os.Stdout)
+func (t *Terminal) WithOutput(w io.Writer) *Terminal {
+	t.out = w
+	return t
+}
+
+// WithSizeFunc overrides how the terminal dimensions are detected, which is
+// useful when rendering to something other than the real terminal
+func (t *Terminal) WithSizeFunc(fn func() (Size, error)) *Terminal {
+	t.sizeFunc = fn
+	return t
+}
+
+// WithOutputMode sets how frames are written (default OutputAuto)
+func (t *Terminal) WithOutputMode(mode OutputMode) *Terminal {
+	t.mode = mode
+	return t
+}
+
+// WithStripANSI removes escape sequences from the output of the
+// non-interactive modes, for logs that do not understand colours
+func (t *Terminal) WithStripANSI(strip bool) *Terminal {
+	t.stripANSI = strip
+	return t
+}
+
+// WithCapabilities replaces the capabilities detected from the environment
+func (t *Terminal) WithCapabilities(caps Capabilities) *Terminal {
+	t.caps = caps
+	return t
+}
+
+// WithColorProfile overrides the detected colour profile. Colours in frames
+// are downsampled to the profile before they are written.
+func (t *Terminal) WithColorProfile(profile ColorProfile) *Terminal {
+	t.caps.Profile = profile
+	return t
+}
+
+// Capabilities returns the capabilities the terminal renders for
+func (t *Terminal) Capabilities() Capabilities {
+	return t.caps
+}
+
+// Interactive reports whether frames are redrawn in place
+func (t *Terminal) Interactive() bool {
+	switch t.mode {
+	case OutputInteractive:
+		return true
+	case OutputFinalFrame, OutputAppend:
+		return false
+	}
+	return t.isTTY
+}
+
+// writer returns the current frame buffer while rendering, or the output
+func (t *Terminal) writer() io.Writer {
+	if t.frame != nil {
+		return t.frame
+	}
+	return t.out
+}
+
+// print writes to the terminal output
+func (t *Terminal) print(a ...any) {
+	fmt.Fprint(t.writer(), a...)
+}
+
+// printf writes formatted output to the terminal output
+func (t *Terminal) printf(format string, a ...any) {
+	fmt.Fprintf(t.writer(), format, a...)
+}
+
+// flushFrame writes the buffered frame to the output in a single write
+func (t *Terminal) flushFrame() {
+	frame := t.frame
+	t.frame = nil
+	if frame.Len() > 0 && t.caps.SynchronizedOutput && t.Interactive() {
+		// Let the terminal present the frame at once instead of line by line
+		frame = bytes.NewBuffer(append(append([]byte("\033[?2026h"), frame.Bytes()...), "\033[?2026l"...))
+	}
+	t.lastRender.Bytes = frame.Len()
+	if frame.Len() > 0 {
+		t.out.Write(frame.Bytes())
+	}
 }
 
 func (t *Terminal) Clear() {
-	fmt.Print("\033[H\033[2J")
+	if !t.Interactive() {
+		return
+	}
+	t.print("\033[H\033[2J")
 }
 
 // HideCursor hides the terminal cursor
 func (t *Terminal) HideCursor() {
-	fmt.Print("\033[?25l")
+	if !t.Interactive() {
+		return
+	}
+	t.print("\033[?25l")
+	t.cursorHidden, t.cursorShown = true, false
 }
 
 // ShowCursor shows the terminal cursor
 func (t *Terminal) ShowCursor() {
-	fmt.Print("\033[?25h")
+	if !t.Interactive() {
+		return
+	}
+	t.print("\033[?25h")
+	t.cursorHidden, t.cursorShown = false, false
 }
 
 // EnableReportFocus enables terminal focus reporting
 func (t *Terminal) EnableReportFocus() {
-	fmt.Print("\033[?1004h")
+	if !t.Interactive() || !t.caps.FocusReporting {
+		return
+	}
+	t.print("\033[?1004h")
 }
 
 // DisableReportFocus disables terminal focus reporting
 func (t *Terminal) DisableReportFocus() {
-	fmt.Print("\033[?1004l")
+	if !t.Interactive() || !t.caps.FocusReporting {
+		return
+	}
+	t.print("\033[?1004l")
+}
+
+// PushKeyboardFlags enables kitty keyboard protocol flags, saving the current
+// ones on the terminal's stack
+func (t *Terminal) PushKeyboardFlags(flags int) {
+	if !t.Interactive() {
+		return
+	}
+	t.printf("\033[>%du", flags)
+}
+
+// PopKeyboardFlags restores the kitty keyboard flags saved by PushKeyboardFlags
+func (t *Terminal) PopKeyboardFlags() {
+	if !t.Interactive() {
+		return
+	}
+	t.print("\033[<u")
+}
+
+// EnableModifyOtherKeys makes xterm compatible terminals report modified keys
+// as CSI 27 ; <mods> ; <code> ~
+func (t *Terminal) EnableModifyOtherKeys() {
+	if !t.Interactive() {
+		return
+	}
+	t.print("\033[>4;2m")
+}
+
+// DisableModifyOtherKeys resets modifyOtherKeys to the terminal default
+func (t *Terminal) DisableModifyOtherKeys() {
+	if !t.Interactive() {
+		return
+	}
+	t.print("\033[>4m")
+}
+
+// SetCursor requests the cursor at pos (0-based column and line within the
+// frame) after the following frames, shown so input methods and screen
+// readers can follow it. A nil pos leaves it at the end of the frame, hidden
+// again if HideCursor was called.
+func (t *Terminal) SetCursor(pos *Position) {
+	t.cursor = pos
+}
+
+// placeCursor moves the cursor from the end of the frame to the requested
+// position, or back to the last line when none is requested
+func (t *Terminal) placeCursor() {
+	if t.firstRender {
+		return
+	}
+	// Nothing was drawn and the cursor stays where it is
+	if t.frame.Len() == 0 && samePosition(t.cursor, t.placed) {
+		return
+	}
+	t.placed = nil
+	if t.cursor != nil {
+		pos := *t.cursor
+		t.placed = &pos
+	}
+
+	bottom := max(t.totalRendered-1, 0)
+	row := bottom
+	if t.cursor != nil {
+		// Lines scrolled off the top cannot be reached
+		row = max(clamp(t.cursor.Y, 0, bottom), t.firstVisibleLine())
+	}
+
+	t.moveLines(row - t.cursorRow)
+	t.cursorRow = row
+
+	if t.cursor == nil {
+		if t.cursorShown {
+			t.print("\033[?25l")
+			t.cursorShown = false
+		}
+		return
+	}
+
+	t.print("\r")
+	if t.cursor.X > 0 {
+		t.printf("\033[%dC", t.cursor.X)
+	}
+	if t.cursorHidden && !t.cursorShown {
+		t.print("\033[?25h")
+		t.cursorShown = true
+	}
+}
+
+// samePosition reports whether two optional positions are equal
+func samePosition(a, b *Position) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// moveLines moves the cursor down n lines, or up for negative n
+func (t *Terminal) moveLines(n int) {
+	if n > 0 {
+		t.printf("\033[%dB", n)
+	} else if n < 0 {
+		t.printf("\033[%dA", -n)
+	}
+}
+
+// QueryCursorPosition asks the terminal for the cursor position (DSR 6). The
+// reply must be read from the input and passed to SetCursorPosition.
+func (t *Terminal) QueryCursorPosition() {
+	if !t.Interactive() {
+		return
+	}
+	lines := -1
+	if !t.firstRender {
+		lines = t.cursorRow + 1
+	}
+	t.cprPending = append(t.cprPending, lines)
+	t.print("\033[6n")
+}
+
+// Query writes a terminal query, e.g. a device status or OSC request; its
+// reply arrives on the input
+func (t *Terminal) Query(seq string) {
+	if !t.Interactive() {
+		return
+	}
+	t.print(seq)
+}
+
+// WriteSequence writes an escape sequence that does not draw anything, such
+// as OSC 52, leaving the frame untouched. Nothing is written when the output
+// is not interactive.
+func (t *Terminal) WriteSequence(seq string) {
+	if !t.Interactive() {
+		return
+	}
+	t.print(seq)
+}
+
+// SetCursorPosition records the reply to QueryCursorPosition, locating the
+// frame on screen
+func (t *Terminal) SetCursorPosition(row, col int) {
+	if len(t.cprPending) == 0 {
+		return
+	}
+	lines := t.cprPending[0]
+	t.cprPending = t.cprPending[1:]
+
+	if lines < 0 {
+		// Queried before anything was drawn: the frame starts right here
+		t.renderStartRow, t.renderStartCol = row, col
+	} else {
+		// Queried with the cursor on frame line lines-1
+		t.renderStartRow = row - (lines - 1)
+	}
+	t.startKnown = true
+	t.trackScroll()
+}
+
+// CancelCursorQueries forgets the outstanding cursor position queries, e.g.
+// when the terminal did not answer them
+func (t *Terminal) CancelCursorQueries() {
+	t.cprPending = nil
+}
+
+// InlineHeight returns the rows from the start of the frame to the bottom of
+// the screen, i.e. how tall the frame can be before the terminal scrolls. It
+// reports false until the cursor position is known.
+func (t *Terminal) InlineHeight() (int, bool) {
+	if !t.startKnown {
+		return 0, false
+	}
+	return max(t.lastSize.Height-max(t.renderStartRow, 1)+1, 1), true
+}
+
+// trackScroll moves the start row up when the frame no longer fits below it
+// and the terminal scrolled
+func (t *Terminal) trackScroll() {
+	if !t.startKnown || t.lastSize.Height == 0 {
+		return
+	}
+	if bottom := t.renderStartRow + t.totalRendered - 1; bottom > t.lastSize.Height {
+		t.renderStartRow -= bottom - t.lastSize.Height
+	}
+}
+
+// firstVisibleLine returns the first line of the frame that is still on
+// screen; the lines above it scrolled off the top
+func (t *Terminal) firstVisibleLine() int {
+	first := 0
+	if t.startKnown {
+		first = 1 - t.renderStartRow
+	}
+	if t.lastSize.Height > 0 {
+		// Frames end on the last row, so a frame taller than the screen lost
+		// at least its first lines even when the start row is unknown
+		first = max(first, t.totalRendered-t.lastSize.Height)
+	}
+	return clamp(first, 0, max(t.totalRendered-1, 0))
 }
 
 // MoveCursor moves the cursor to a specific position (1-based coordinates)
 func (t *Terminal) MoveCursor(row, col int) {
-	fmt.Printf("\033[%d;%dH", row, col)
+	if !t.Interactive() {
+		return
+	}
+	t.printf("\033[%d;%dH", row, col)
 }
 
 // MoveCursorHome moves the cursor to the top-left corner
 func (t *Terminal) MoveCursorHome() {
-	fmt.Print("\033[H")
+	if !t.Interactive() {
+		return
+	}
+	t.print("\033[H")
 }
 
 
 // GetSize returns the current terminal dimensions
 func (t *Terminal) GetSize() (Size, error) {
+	if t.sizeFunc != nil {
+		return t.sizeFunc()
+	}
+
 	// Try to get size using term.GetSize (same method as bubbletea)
 	width, height, err := term.GetSize(os.Stdout.Fd())
 	if err == nil {
 		return Size{Width: width, Height: height}, nil
 	}
 
+	// Without a terminal there is nothing stty could report; use the
+	// conventional environment variables instead
+	if !t.isTTY {
+		return sizeFromEnv()
+	}
+
 	// Fallback to stty if term.GetSize fails
 	cmd := exec.Command("stty", "size")
 	cmd.Stdin = os.Stdin
@@ -94,14 +460,42 @@ func (t *Terminal) GetSize() (Size, error) {
 	return Size{Width: sttyWidth, Height: sttyHeight}, nil
 }
 
+// sizeFromEnv reads the size from $COLUMNS and $LINES, defaulting to 80x24
+func sizeFromEnv() (Size, error) {
+	width, errW := strconv.Atoi(os.Getenv("COLUMNS"))
+	height, errH := strconv.Atoi(os.Getenv("LINES"))
+	if errW != nil || errH != nil || width <= 0 || height <= 0 {
+		return Size{Width: 80, Height: 24}, fmt.Errorf("not a terminal")
+	}
+	return Size{Width: width, Height: height}, nil
+}
+
 // RenderString renders a string directly to the terminal with differential updates
 func (t *Terminal) RenderString(content string) {
-	lines := strings.Split(content, "\n")
+	lines := strings.Split(DownsampleANSI(content, t.caps.Profile), "\n")
+
+	if !t.Interactive() {
+		t.renderFallback(lines)
+		return
+	}
+
+	// Collect the frame so it reaches the output in one write
+	t.frame = &bytes.Buffer{}
+	defer t.flushFrame()
+	defer t.placeCursor()
 	
 	// Check for size changes to force full re-render
 	currentSize, _ := t.GetSize()
 	sizeChanged := t.lastSize.Width != currentSize.Width || t.lastSize.Height != currentSize.Height
 	t.lastSize = currentSize
+
+	// Keep each line on a single row, as the cursor movement below counts
+	// lines; a line wider than the terminal would wrap onto the next row
+	if currentSize.Width > 0 {
+		for i, line := range lines {
+			lines[i] = Truncate(line, currentSize.Width, "")
+		}
+	}
 	
 	// First render - just render content from current cursor position
 	if t.firstRender {
@@ -110,34 +504,41 @@ func (t *Terminal) RenderString(content string) {
 		// Render all content without clearing screen
 		for i, line := range lines {
 			if i > 0 {
-				fmt.Print("\n")
+				t.print("\n")
 			}
-			fmt.Print(line)
+			t.print(line)
 		}
 		
 		// Update state
 		t.previousBuffer = make([]string, len(lines))
 		copy(t.previousBuffer, lines)
 		t.totalRendered = len(lines)
+		t.cursorRow = len(lines) - 1
+		t.trackScroll()
+		t.lastRender = renderStats{Mode: "initial", Lines: len(lines)}
 		return
 	}
 	
 	// Size changed - clear screen and re-render to avoid artifacts
 	if sizeChanged {
-		fmt.Print("\033[H\033[2J") // Clear screen and go to home
+		t.print("\033[H\033[2J") // Clear screen and go to home
 		
 		// Render all content
 		for i, line := range lines {
 			if i > 0 {
-				fmt.Print("\n")
+				t.print("\n")
 			}
-			fmt.Print(line)
+			t.print(line)
 		}
 		
 		// Update state
 		t.previousBuffer = make([]string, len(lines))
 		copy(t.previousBuffer, lines)
 		t.totalRendered = len(lines)
+		t.cursorRow = len(lines) - 1
+		t.renderStartRow, t.renderStartCol, t.startKnown = 1, 1, true
+		t.trackScroll()
+		t.lastRender = renderStats{Mode: "full", Lines: len(lines)}
 		return
 	}
 	
@@ -158,37 +559,156 @@ func (t *Terminal) RenderString(content string) {
 	// If lengths differ but common lines are same, start diff at end of common
 	if firstDiff == -1 && len(lines) != len(t.previousBuffer) {
 		firstDiff = minLen
+		// When lines were only removed, redraw the last one kept so the
+		// cursor ends on it with the rest of the old frame cleared below
+		if firstDiff == len(lines) && firstDiff > 0 {
+			firstDiff--
+		}
 	}
 	
 	// No changes needed
 	if firstDiff == -1 {
+		t.lastRender = renderStats{Mode: "unchanged", FirstLine: len(lines)}
 		return
 	}
 	
 	// Move cursor to the first line that needs updating
-	// We need to go back up from where we currently are (end of previous render)
-	// to the first differing line
-	linesToGoBack := t.totalRendered - 1 - firstDiff
-	if linesToGoBack > 0 {
-		fmt.Printf("\033[%dA", linesToGoBack) // Move up
+	// We need to go back up from where we currently are (end of previous
+	// render, or the line the cursor was placed on) to the first differing line
+	if visible := t.firstVisibleLine(); firstDiff < visible {
+		// Lines that scrolled off the top can no longer be redrawn and cursor
+		// up stops at the first row. Redraw from there, starting with as much
+		// of the new frame as still fits on screen.
+		t.moveLines(visible - t.cursorRow)
+		start := min(visible, max(len(lines)-t.lastSize.Height, 0))
+		t.renderStartRow += visible - start
+		firstDiff = start
+	} else {
+		t.moveLines(firstDiff - t.cursorRow)
 	}
-	fmt.Print("\r") // Move to beginning of line
+	t.print("\r") // Move to beginning of line
 	
 	// Clear from current position to end of screen
-	fmt.Print("\033[J")
+	t.print("\033[J")
 	
+	// A hyperlink opened on an earlier line continues into the redrawn ones
+	t.print(openHyperlink(lines[:min(firstDiff, len(lines))]))
+
 	// Render changed lines
 	for i := firstDiff; i < len(lines); i++ {
 		if i > firstDiff {
-			fmt.Print("\n")
+			t.print("\n")
 		}
-		fmt.Print(lines[i])
+		t.print(lines[i])
 	}
 	
 	// Update state
 	t.previousBuffer = make([]string, len(lines))
 	copy(t.previousBuffer, lines)
 	t.totalRendered = len(lines)
+	t.cursorRow = len(lines) - 1
+	t.trackScroll()
+	t.lastRender = renderStats{Mode: "diff", FirstLine: firstDiff, Lines: len(lines) - firstDiff}
+}
+
+// renderFallback records the frame for non-interactive output, writing the
+// new or changed lines in OutputAppend mode
+func (t *Terminal) renderFallback(lines []string) {
+	mode := t.mode
+	if mode == OutputAuto {
+		mode = OutputFinalFrame
+	}
+
+	written := 0
+	if mode == OutputAppend {
+		t.frame = &bytes.Buffer{}
+		defer t.flushFrame()
+
+		for i, line := range lines {
+			if i == len(lines)-1 && line == "" {
+				// Trailing newline of the view
+				break
+			}
+			if i < len(t.previousBuffer) && t.previousBuffer[i] == line {
+				continue
+			}
+			t.print(t.plain(line), "\n")
+			written++
+		}
+	}
+
+	t.previousBuffer = make([]string, len(lines))
+	copy(t.previousBuffer, lines)
+	t.totalRendered = len(lines)
+	t.firstRender = false
+	t.lastRender = renderStats{Mode: "fallback", Lines: written}
+}
+
+// plain strips escape sequences when requested
+func (t *Terminal) plain(s string) string {
+	if t.stripANSI {
+		return StripANSI(s)
+	}
+	return s
+}
+
+// Commit prints text above the live frame. Unlike the frame, committed text is
+// never redrawn and stays in the terminal history.
+func (t *Terminal) Commit(text string) {
+	text = DownsampleANSI(text, t.caps.Profile)
+	t.frame = &bytes.Buffer{}
+	defer t.flushFrame()
+
+	if !t.Interactive() {
+		t.print(t.plain(text), "\n")
+		return
+	}
+
+	if t.firstRender {
+		t.print(text, "\n")
+		return
+	}
+
+	// Replace the frame with the text, then draw the frame again below it
+	defer t.placeCursor()
+	t.moveLines(-t.cursorRow)
+	t.print("\r\033[J")
+	t.print(text, "\n")
+	for i, line := range t.previousBuffer {
+		if i > 0 {
+			t.print("\n")
+		}
+		t.print(line)
+	}
+	t.cursorRow = max(len(t.previousBuffer)-1, 0)
+	t.renderStartRow += strings.Count(text, "\n") + 1
+	t.trackScroll()
+}
+
+// Finish writes the final frame in OutputFinalFrame mode and ends the output
+// with a newline so a shell prompt starts on its own line
+func (t *Terminal) Finish() {
+	if t.progress {
+		t.SetProgress(ProgressNone, 0)
+	}
+	if t.Interactive() {
+		if !t.firstRender {
+			t.moveLines(t.totalRendered - 1 - t.cursorRow)
+		}
+		t.print("\n")
+		return
+	}
+
+	if t.mode == OutputAppend {
+		return
+	}
+	lines := t.previousBuffer
+	if n := len(lines); n > 0 && lines[n-1] == "" {
+		lines = lines[:n-1]
+	}
+	for _, line := range lines {
+		t.print(t.plain(line), "\n")
+	}
 }
 
 // ClearPreviousBuffer clears the stored previous buffer (useful for manual redraws)
@@ -0,0 +1,105 @@
+package brew
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNonInteractiveOutput(t *testing.T) {
+	// Each step renders a frame, or commits text when prefixed with '+'
+	tests := []struct {
+		name  string
+		mode  OutputMode
+		strip bool
+		steps []string
+		want  string
+	}{
+		{
+			name:  "final frame writes only the last frame",
+			mode:  OutputFinalFrame,
+			steps: []string{"a\nb\n", "a\nc\n"},
+			want:  "a\nc\n",
+		},
+		{
+			name:  "auto falls back to the final frame",
+			mode:  OutputAuto,
+			steps: []string{"a\n", "b\n"},
+			want:  "b\n",
+		},
+		{
+			name:  "committed text is written as it happens",
+			mode:  OutputFinalFrame,
+			steps: []string{"frame 1", "+done", "frame 2"},
+			want:  "done\nframe 2\n",
+		},
+		{
+			name:  "append writes new and changed lines",
+			mode:  OutputAppend,
+			steps: []string{"a\nb\n", "a\nc\n", "a\nc\nd\n"},
+			want:  "a\nb\nc\nd\n",
+		},
+		{
+			name:  "append writes an unchanged frame once",
+			mode:  OutputAppend,
+			steps: []string{"a", "a", "+log", "a"},
+			want:  "a\nlog\n",
+		},
+		{
+			name:  "append keeps blank lines inside the frame",
+			mode:  OutputAppend,
+			steps: []string{"a\n\nb"},
+			want:  "a\n\nb\n",
+		},
+		{
+			name:  "escape sequences are kept",
+			mode:  OutputFinalFrame,
+			steps: []string{"+\x1b[1mlog\x1b[m", "\x1b[31mred\x1b[m"},
+			want:  "\x1b[1mlog\x1b[m\n\x1b[31mred\x1b[m\n",
+		},
+		{
+			name:  "escape sequences are stripped",
+			mode:  OutputAppend,
+			strip: true,
+			steps: []string{"+\x1b[1mlog\x1b[m", "\x1b[31mred\x1b[m"},
+			want:  "log\nred\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			term := NewTerminal().WithOutput(&buf).WithOutputMode(tt.mode).
+				WithStripANSI(tt.strip).WithColorProfile(ProfileTrueColor)
+			term.isTTY = false
+
+			for _, step := range tt.steps {
+				if text, ok := strings.CutPrefix(step, "+"); ok {
+					term.Commit(text)
+				} else {
+					term.RenderString(step)
+				}
+			}
+			term.Finish()
+			if got := buf.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderFallbackStats(t *testing.T) {
+	var buf bytes.Buffer
+	term := NewTerminal().WithOutput(&buf).WithOutputMode(OutputAppend)
+
+	term.RenderString("a\nb\n")
+	if got := term.lastRender; got.Mode != "fallback" || got.Lines != 2 {
+		t.Errorf("first frame stats = %+v, want 2 fallback lines", got)
+	}
+	term.RenderString("a\nc\n")
+	if got := term.lastRender.Lines; got != 1 {
+		t.Errorf("second frame wrote %d lines, want 1", got)
+	}
+	if term.firstRender {
+		t.Error("firstRender still set after a fallback frame")
+	}
+}
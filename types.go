@@ -1,6 +1,10 @@
 package brew
 
+import "fmt"
+
 // Color represents terminal colors using ANSI escape codes
+//
+// Deprecated: styles take a CellColor, convert with Color.Cell.
 type Color string
 
 const (
@@ -28,6 +32,22 @@ const (
 	ColorReset Color = "\033[0m"
 )
 
+// Cell converts the colour to a CellColor; ColorDefault and ColorReset become
+// the default colour
+func (c Color) Cell() CellColor {
+	var n int
+	if _, err := fmt.Sscanf(string(c), "\033[%dm", &n); err != nil {
+		return CellColor{}
+	}
+	switch {
+	case n >= 30 && n <= 37:
+		return BasicColor(n - 30)
+	case n >= 90 && n <= 97:
+		return BasicColor(n - 90 + 8)
+	}
+	return CellColor{}
+}
+
 // Direction represents flex direction
 type Direction int
 
@@ -76,10 +96,12 @@ type Position struct {
 type Style struct {
 	Border        bool
 	BorderChar    rune
-	BorderColor   Color
+	BorderColor   CellColor // border colour
 	RoundedBorder bool
 	Padding       Box
 	Margin        Box
 	BgChar        rune
+	Foreground    CellColor // text colour
+	Background    CellColor // colour filling the box
 }
 
@@ -0,0 +1,817 @@
+package brew
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// ColorKind identifies how a CellColor value should be interpreted
+type ColorKind uint8
+
+const (
+	ColorKindDefault ColorKind = iota // terminal default colour
+	ColorKindBasic                    // one of the 16 ANSI colours (0-15)
+	ColorKindIndexed                  // xterm 256 colour palette index
+	ColorKindRGB                      // 24-bit truecolor, packed as 0xRRGGBB
+)
+
+// CellColor is a foreground or background colour of a single cell
+type CellColor struct {
+	Kind  ColorKind
+	Value uint32
+}
+
+// BasicColor returns one of the 16 ANSI colours
+func BasicColor(n int) CellColor {
+	return CellColor{Kind: ColorKindBasic, Value: uint32(n & 0x0f)}
+}
+
+// IndexedColor returns a colour from the xterm 256 colour palette
+func IndexedColor(n int) CellColor {
+	return CellColor{Kind: ColorKindIndexed, Value: uint32(n & 0xff)}
+}
+
+// RGBColor returns a 24-bit truecolor
+func RGBColor(r, g, b uint8) CellColor {
+	return CellColor{Kind: ColorKindRGB, Value: uint32(r)<<16 | uint32(g)<<8 | uint32(b)}
+}
+
+// Attr is a bit set of text attributes
+type Attr uint16
+
+const (
+	AttrBold Attr = 1 << iota
+	AttrFaint
+	AttrItalic
+	AttrUnderline
+	AttrBlink
+	AttrReverse
+	AttrConceal
+	AttrStrikethrough
+)
+
+// Cell is a single character cell on the screen
+type Cell struct {
+	Rune  rune
+	Width int // 0 for the trailing half of a wide character
+	Fg    CellColor
+	Bg    CellColor
+	Attrs Attr
+	Link  string // OSC 8 hyperlink target
+}
+
+// blankCell returns an empty cell painted with the given pen
+func blankCell(pen Cell) Cell {
+	return Cell{Rune: ' ', Width: 1, Bg: pen.Bg}
+}
+
+// VirtualTerminal is a small VT100/xterm emulator. It interprets the escape
+// sequences coldbrew writes (cursor movement, erase, wrapping, scroll regions
+// and SGR) and keeps both the visible grid and the lines that scrolled off the
+// top, so the result of a sequence of frames can be inspected. Like a tty with
+// ONLCR set, a line feed also returns the cursor to the first column.
+type VirtualTerminal struct {
+	mu sync.Mutex
+
+	width      int
+	height     int
+	grid       [][]Cell
+	scrollback [][]Cell
+
+	cursorX       int
+	cursorY       int
+	pendingWrap   bool
+	cursorVisible bool
+	autoWrap      bool
+	pen           Cell
+
+	scrollTop    int
+	scrollBottom int
+
+	savedX   int
+	savedY   int
+	savedPen Cell
+
+	state  vtState
+	params []byte
+	utf8   []byte
+}
+
+// vtState is the state of the escape sequence parser
+type vtState int
+
+const (
+	vtGround vtState = iota
+	vtEscape
+	vtCharset
+	vtCSI
+	vtString
+	vtStringEscape
+)
+
+// NewVirtualTerminal creates an emulator with the given screen size
+func NewVirtualTerminal(width, height int) *VirtualTerminal {
+	vt := &VirtualTerminal{}
+	vt.reset(width, height)
+	return vt
+}
+
+// reset restores the power-on state
+func (vt *VirtualTerminal) reset(width, height int) {
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	vt.width = width
+	vt.height = height
+	vt.grid = make([][]Cell, height)
+	for i := range vt.grid {
+		vt.grid[i] = vt.blankLine()
+	}
+	vt.scrollback = nil
+	vt.cursorX, vt.cursorY = 0, 0
+	vt.pendingWrap = false
+	vt.cursorVisible = true
+	vt.autoWrap = true
+	vt.pen = Cell{}
+	vt.scrollTop, vt.scrollBottom = 0, height-1
+	vt.savedX, vt.savedY, vt.savedPen = 0, 0, Cell{}
+	vt.state = vtGround
+}
+
+// Write feeds terminal output into the emulator
+func (vt *VirtualTerminal) Write(p []byte) (int, error) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+
+	for _, b := range p {
+		vt.feed(b)
+	}
+	return len(p), nil
+}
+
+// Size returns the screen dimensions
+func (vt *VirtualTerminal) Size() Size {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	return Size{Width: vt.width, Height: vt.height}
+}
+
+// Resize changes the screen dimensions. Rows that no longer fit above the
+// cursor are pushed into the scrollback, like most terminals do.
+func (vt *VirtualTerminal) Resize(width, height int) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	for len(vt.grid) > height {
+		if vt.cursorY > 0 {
+			vt.scrollback = append(vt.scrollback, vt.grid[0])
+			vt.grid = vt.grid[1:]
+			vt.cursorY--
+		} else {
+			vt.grid = vt.grid[:len(vt.grid)-1]
+		}
+	}
+	vt.width = width
+	for len(vt.grid) < height {
+		vt.grid = append(vt.grid, vt.blankLine())
+	}
+	for i, line := range vt.grid {
+		vt.grid[i] = vt.fitLine(line)
+	}
+
+	vt.height = height
+	vt.scrollTop, vt.scrollBottom = 0, height-1
+	vt.cursorX = min(vt.cursorX, width-1)
+	vt.cursorY = min(vt.cursorY, height-1)
+	vt.pendingWrap = false
+}
+
+// Screen returns the visible rows with trailing blanks removed
+func (vt *VirtualTerminal) Screen() []string {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	return linesToStrings(vt.grid)
+}
+
+// Scrollback returns the rows that scrolled off the top of the screen,
+// oldest first
+func (vt *VirtualTerminal) Scrollback() []string {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	return linesToStrings(vt.scrollback)
+}
+
+// Cell returns the cell at the given zero-based screen coordinates
+func (vt *VirtualTerminal) Cell(x, y int) Cell {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+
+	if y < 0 || y >= vt.height || x < 0 || x >= vt.width {
+		return Cell{}
+	}
+	return vt.grid[y][x]
+}
+
+// Cursor returns the zero-based cursor position
+func (vt *VirtualTerminal) Cursor() Position {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	return Position{X: vt.cursorX, Y: vt.cursorY}
+}
+
+// CursorVisible reports whether the cursor is shown
+func (vt *VirtualTerminal) CursorVisible() bool {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	return vt.cursorVisible
+}
+
+// String returns the scrollback followed by the screen, without the empty
+// rows below the last written line
+func (vt *VirtualTerminal) String() string {
+	lines := append(vt.Scrollback(), vt.Screen()...)
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// linesToStrings converts rows of cells into plain text
+func linesToStrings(rows [][]Cell) []string {
+	out := make([]string, len(rows))
+	for i, row := range rows {
+		var sb strings.Builder
+		for _, c := range row {
+			if c.Width == 0 {
+				continue
+			}
+			sb.WriteRune(c.Rune)
+		}
+		out[i] = strings.TrimRight(sb.String(), " ")
+	}
+	return out
+}
+
+// blankLine returns an empty row painted with the current background
+func (vt *VirtualTerminal) blankLine() []Cell {
+	line := make([]Cell, vt.width)
+	for i := range line {
+		line[i] = blankCell(vt.pen)
+	}
+	return line
+}
+
+// fitLine truncates or pads a row to the screen width
+func (vt *VirtualTerminal) fitLine(line []Cell) []Cell {
+	if len(line) > vt.width {
+		return line[:vt.width]
+	}
+	for len(line) < vt.width {
+		line = append(line, blankCell(Cell{}))
+	}
+	return line
+}
+
+// feed processes a single byte of output
+func (vt *VirtualTerminal) feed(b byte) {
+	switch vt.state {
+	case vtGround:
+		vt.ground(b)
+	case vtEscape:
+		vt.escape(b)
+	case vtCharset:
+		// Character set designation is consumed but not emulated
+		vt.state = vtGround
+	case vtCSI:
+		switch {
+		case b >= 0x40 && b <= 0x7e:
+			vt.csi(b)
+			vt.state = vtGround
+		case b == 0x1b:
+			vt.state = vtEscape
+		case b < 0x20:
+			vt.control(b)
+		default:
+			vt.params = append(vt.params, b)
+		}
+	case vtString:
+		// OSC, DCS and friends are terminated by BEL or ST and have no
+		// effect on the grid
+		switch b {
+		case 0x07:
+			vt.state = vtGround
+		case 0x1b:
+			vt.state = vtStringEscape
+		}
+	case vtStringEscape:
+		if b == '\\' {
+			vt.state = vtGround
+		} else {
+			vt.state = vtString
+		}
+	}
+}
+
+// ground handles bytes outside of escape sequences
+func (vt *VirtualTerminal) ground(b byte) {
+	if len(vt.utf8) == 0 && b < 0x20 {
+		vt.control(b)
+		return
+	}
+	if len(vt.utf8) == 0 && b < 0x80 {
+		if b != 0x7f {
+			vt.print(rune(b))
+		}
+		return
+	}
+
+	vt.utf8 = append(vt.utf8, b)
+	if !utf8.FullRune(vt.utf8) {
+		return
+	}
+	r, _ := utf8.DecodeRune(vt.utf8)
+	vt.utf8 = vt.utf8[:0]
+	vt.print(r)
+}
+
+// control executes a C0 control character
+func (vt *VirtualTerminal) control(b byte) {
+	switch b {
+	case 0x1b:
+		vt.params = vt.params[:0]
+		vt.state = vtEscape
+	case '\r':
+		vt.cursorX = 0
+		vt.pendingWrap = false
+	case '\n', 0x0b, 0x0c:
+		vt.cursorX = 0
+		vt.lineFeed()
+	case '\b':
+		if vt.cursorX > 0 {
+			vt.cursorX--
+		}
+		vt.pendingWrap = false
+	case '\t':
+		vt.cursorX = min((vt.cursorX/8+1)*8, vt.width-1)
+		vt.pendingWrap = false
+	}
+}
+
+// escape handles the byte following ESC
+func (vt *VirtualTerminal) escape(b byte) {
+	vt.state = vtGround
+	switch b {
+	case '[':
+		vt.params = vt.params[:0]
+		vt.state = vtCSI
+	case ']', 'P', 'X', '^', '_':
+		vt.state = vtString
+	case '(', ')', '*', '+':
+		vt.state = vtCharset
+	case '7':
+		vt.saveCursor()
+	case '8':
+		vt.restoreCursor()
+	case 'D':
+		vt.lineFeed()
+	case 'E':
+		vt.cursorX = 0
+		vt.lineFeed()
+	case 'M':
+		vt.reverseIndex()
+	case 'c':
+		vt.reset(vt.width, vt.height)
+	}
+}
+
+// print writes a printable rune at the cursor
+func (vt *VirtualTerminal) print(r rune) {
+	w := runewidth.RuneWidth(r)
+	if w == 0 {
+		// Combining characters are not tracked separately
+		return
+	}
+	if w > vt.width {
+		// A wide character cannot fit on a one column screen
+		r, w = ' ', 1
+	}
+
+	if vt.pendingWrap && vt.autoWrap {
+		vt.cursorX = 0
+		vt.lineFeed()
+	}
+	vt.pendingWrap = false
+
+	if vt.cursorX+w > vt.width {
+		if !vt.autoWrap {
+			vt.cursorX = vt.width - w
+		} else {
+			vt.cursorX = 0
+			vt.lineFeed()
+		}
+	}
+
+	line := vt.grid[vt.cursorY]
+	breakWideCell(line, vt.cursorX)
+	line[vt.cursorX] = Cell{Rune: r, Width: w, Fg: vt.pen.Fg, Bg: vt.pen.Bg, Attrs: vt.pen.Attrs}
+	if w == 2 && vt.cursorX+1 < vt.width {
+		breakWideCell(line, vt.cursorX+1)
+		line[vt.cursorX+1] = Cell{Width: 0, Fg: vt.pen.Fg, Bg: vt.pen.Bg, Attrs: vt.pen.Attrs}
+	}
+
+	if vt.cursorX+w >= vt.width {
+		vt.cursorX = vt.width - 1
+		vt.pendingWrap = true
+	} else {
+		vt.cursorX += w
+	}
+}
+
+// breakWideCell blanks the other half of a wide character covering x, which
+// is about to be overwritten
+func breakWideCell(line []Cell, x int) {
+	switch c := line[x]; {
+	case c.Width == 2 && x+1 < len(line):
+		line[x+1] = blankCell(c)
+	case c.Width == 0 && x > 0 && line[x-1].Width == 2:
+		line[x-1] = blankCell(line[x-1])
+	}
+}
+
+// lineFeed moves the cursor down, scrolling the region when at its bottom
+func (vt *VirtualTerminal) lineFeed() {
+	vt.pendingWrap = false
+	if vt.cursorY == vt.scrollBottom {
+		vt.scrollUp(1)
+		return
+	}
+	if vt.cursorY < vt.height-1 {
+		vt.cursorY++
+	}
+}
+
+// reverseIndex moves the cursor up, scrolling the region when at its top
+func (vt *VirtualTerminal) reverseIndex() {
+	vt.pendingWrap = false
+	if vt.cursorY == vt.scrollTop {
+		vt.scrollDown(1)
+		return
+	}
+	if vt.cursorY > 0 {
+		vt.cursorY--
+	}
+}
+
+// scrollUp scrolls the scroll region up by n rows. Rows leaving a full-screen
+// region are kept in the scrollback.
+func (vt *VirtualTerminal) scrollUp(n int) {
+	for ; n > 0; n-- {
+		top := vt.grid[vt.scrollTop]
+		if vt.scrollTop == 0 && vt.scrollBottom == vt.height-1 {
+			vt.scrollback = append(vt.scrollback, top)
+		}
+		copy(vt.grid[vt.scrollTop:vt.scrollBottom], vt.grid[vt.scrollTop+1:vt.scrollBottom+1])
+		vt.grid[vt.scrollBottom] = vt.blankLine()
+	}
+}
+
+// scrollDown scrolls the scroll region down by n rows
+func (vt *VirtualTerminal) scrollDown(n int) {
+	for ; n > 0; n-- {
+		copy(vt.grid[vt.scrollTop+1:vt.scrollBottom+1], vt.grid[vt.scrollTop:vt.scrollBottom])
+		vt.grid[vt.scrollTop] = vt.blankLine()
+	}
+}
+
+// saveCursor implements DECSC
+func (vt *VirtualTerminal) saveCursor() {
+	vt.savedX, vt.savedY, vt.savedPen = vt.cursorX, vt.cursorY, vt.pen
+}
+
+// restoreCursor implements DECRC
+func (vt *VirtualTerminal) restoreCursor() {
+	vt.cursorX, vt.cursorY, vt.pen = vt.savedX, vt.savedY, vt.savedPen
+	vt.pendingWrap = false
+}
+
+// csi dispatches a complete control sequence
+func (vt *VirtualTerminal) csi(final byte) {
+	raw := string(vt.params)
+	var prefix byte
+	if raw != "" && strings.IndexByte("?<=>", raw[0]) >= 0 {
+		prefix = raw[0]
+		raw = raw[1:]
+	}
+	if i := strings.IndexAny(raw, " !\"#$%&'()*+,-./"); i >= 0 {
+		// Sequences with intermediates (DECSCUSR, DECRQM, ...) are ignored
+		return
+	}
+	params := parseParams(raw)
+	arg := func(i, def int) int {
+		if i < len(params) && params[i] > 0 {
+			return params[i]
+		}
+		return def
+	}
+
+	if prefix == '?' {
+		if final == 'h' || final == 'l' {
+			vt.setPrivateModes(params, final == 'h')
+		}
+		return
+	}
+	if prefix != 0 {
+		return
+	}
+
+	if final != 'm' {
+		vt.pendingWrap = false
+	}
+
+	switch final {
+	case 'A':
+		vt.cursorUp(arg(0, 1))
+	case 'B', 'e':
+		vt.cursorDown(arg(0, 1))
+	case 'C', 'a':
+		vt.cursorX = min(vt.cursorX+arg(0, 1), vt.width-1)
+	case 'D':
+		vt.cursorX = max(vt.cursorX-arg(0, 1), 0)
+	case 'E':
+		vt.cursorDown(arg(0, 1))
+		vt.cursorX = 0
+	case 'F':
+		vt.cursorUp(arg(0, 1))
+		vt.cursorX = 0
+	case 'G', '`':
+		vt.cursorX = clamp(arg(0, 1)-1, 0, vt.width-1)
+	case 'd':
+		vt.cursorY = clamp(arg(0, 1)-1, 0, vt.height-1)
+	case 'H', 'f':
+		vt.cursorY = clamp(arg(0, 1)-1, 0, vt.height-1)
+		vt.cursorX = clamp(arg(1, 1)-1, 0, vt.width-1)
+	case 'J':
+		vt.eraseDisplay(arg(0, 0))
+	case 'K':
+		vt.eraseLine(arg(0, 0))
+	case 'X':
+		vt.eraseCells(vt.cursorY, vt.cursorX, vt.cursorX+arg(0, 1))
+	case '@':
+		vt.insertCells(arg(0, 1))
+	case 'P':
+		vt.deleteCells(arg(0, 1))
+	case 'L':
+		vt.insertLines(arg(0, 1))
+	case 'M':
+		vt.deleteLines(arg(0, 1))
+	case 'S':
+		vt.scrollUp(arg(0, 1))
+	case 'T':
+		vt.scrollDown(arg(0, 1))
+	case 'r':
+		top := arg(0, 1) - 1
+		bottom := arg(1, vt.height) - 1
+		if top < bottom && bottom < vt.height {
+			vt.scrollTop, vt.scrollBottom = top, bottom
+			vt.cursorX, vt.cursorY = 0, 0
+		}
+	case 's':
+		vt.saveCursor()
+	case 'u':
+		vt.restoreCursor()
+	case 'm':
+		vt.pen.applySGR(params, Cell{})
+	}
+}
+
+// cursorUp moves up, stopping at the top margin when inside the region
+func (vt *VirtualTerminal) cursorUp(n int) {
+	limit := 0
+	if vt.cursorY >= vt.scrollTop {
+		limit = vt.scrollTop
+	}
+	vt.cursorY = max(vt.cursorY-n, limit)
+}
+
+// cursorDown moves down, stopping at the bottom margin when inside the region
+func (vt *VirtualTerminal) cursorDown(n int) {
+	limit := vt.height - 1
+	if vt.cursorY <= vt.scrollBottom {
+		limit = vt.scrollBottom
+	}
+	vt.cursorY = min(vt.cursorY+n, limit)
+}
+
+// setPrivateModes handles DECSET/DECRST
+func (vt *VirtualTerminal) setPrivateModes(modes []int, enable bool) {
+	for _, mode := range modes {
+		switch mode {
+		case 7:
+			vt.autoWrap = enable
+		case 25:
+			vt.cursorVisible = enable
+		}
+	}
+}
+
+// eraseDisplay implements ED
+func (vt *VirtualTerminal) eraseDisplay(mode int) {
+	switch mode {
+	case 0:
+		vt.eraseCells(vt.cursorY, vt.cursorX, vt.width)
+		for y := vt.cursorY + 1; y < vt.height; y++ {
+			vt.grid[y] = vt.blankLine()
+		}
+	case 1:
+		for y := 0; y < vt.cursorY; y++ {
+			vt.grid[y] = vt.blankLine()
+		}
+		vt.eraseCells(vt.cursorY, 0, vt.cursorX+1)
+	case 2:
+		for y := range vt.grid {
+			vt.grid[y] = vt.blankLine()
+		}
+	case 3:
+		vt.scrollback = nil
+	}
+}
+
+// eraseLine implements EL
+func (vt *VirtualTerminal) eraseLine(mode int) {
+	switch mode {
+	case 0:
+		vt.eraseCells(vt.cursorY, vt.cursorX, vt.width)
+	case 1:
+		vt.eraseCells(vt.cursorY, 0, vt.cursorX+1)
+	case 2:
+		vt.eraseCells(vt.cursorY, 0, vt.width)
+	}
+}
+
+// eraseCells blanks the cells [from, to) of a row
+func (vt *VirtualTerminal) eraseCells(y, from, to int) {
+	line := vt.grid[y]
+	for x := max(from, 0); x < min(to, vt.width); x++ {
+		line[x] = blankCell(vt.pen)
+	}
+}
+
+// insertCells implements ICH
+func (vt *VirtualTerminal) insertCells(n int) {
+	line := vt.grid[vt.cursorY]
+	n = min(n, vt.width-vt.cursorX)
+	copy(line[vt.cursorX+n:], line[vt.cursorX:vt.width-n])
+	vt.eraseCells(vt.cursorY, vt.cursorX, vt.cursorX+n)
+}
+
+// deleteCells implements DCH
+func (vt *VirtualTerminal) deleteCells(n int) {
+	line := vt.grid[vt.cursorY]
+	n = min(n, vt.width-vt.cursorX)
+	copy(line[vt.cursorX:], line[vt.cursorX+n:])
+	vt.eraseCells(vt.cursorY, vt.width-n, vt.width)
+}
+
+// insertLines implements IL
+func (vt *VirtualTerminal) insertLines(n int) {
+	if vt.cursorY < vt.scrollTop || vt.cursorY > vt.scrollBottom {
+		return
+	}
+	top := vt.scrollTop
+	vt.scrollTop = vt.cursorY
+	vt.scrollDown(n)
+	vt.scrollTop = top
+	vt.cursorX = 0
+}
+
+// deleteLines implements DL
+func (vt *VirtualTerminal) deleteLines(n int) {
+	if vt.cursorY < vt.scrollTop || vt.cursorY > vt.scrollBottom {
+		return
+	}
+	top := vt.scrollTop
+	vt.scrollTop = vt.cursorY
+	for ; n > 0; n-- {
+		copy(vt.grid[vt.scrollTop:vt.scrollBottom], vt.grid[vt.scrollTop+1:vt.scrollBottom+1])
+		vt.grid[vt.scrollBottom] = vt.blankLine()
+	}
+	vt.scrollTop = top
+	vt.cursorX = 0
+}
+
+// applySGR applies Select Graphic Rendition parameters to a pen. A reset
+// returns to base, keeping the hyperlink.
+func (c *Cell) applySGR(params []int, base Cell) {
+	if len(params) == 0 {
+		params = []int{0}
+	}
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		switch {
+		case p == 0:
+			*c = Cell{Fg: base.Fg, Bg: base.Bg, Attrs: base.Attrs, Link: c.Link}
+		case p == 1:
+			c.Attrs |= AttrBold
+		case p == 2:
+			c.Attrs |= AttrFaint
+		case p == 3:
+			c.Attrs |= AttrItalic
+		case p == 4:
+			c.Attrs |= AttrUnderline
+		case p == 5 || p == 6:
+			c.Attrs |= AttrBlink
+		case p == 7:
+			c.Attrs |= AttrReverse
+		case p == 8:
+			c.Attrs |= AttrConceal
+		case p == 9:
+			c.Attrs |= AttrStrikethrough
+		case p == 21 || p == 22:
+			c.Attrs &^= AttrBold | AttrFaint
+		case p == 23:
+			c.Attrs &^= AttrItalic
+		case p == 24:
+			c.Attrs &^= AttrUnderline
+		case p == 25:
+			c.Attrs &^= AttrBlink
+		case p == 27:
+			c.Attrs &^= AttrReverse
+		case p == 28:
+			c.Attrs &^= AttrConceal
+		case p == 29:
+			c.Attrs &^= AttrStrikethrough
+		case p >= 30 && p <= 37:
+			c.Fg = BasicColor(p - 30)
+		case p == 38:
+			c.Fg, i = extendedColor(params, i)
+		case p == 39:
+			c.Fg = base.Fg
+		case p >= 40 && p <= 47:
+			c.Bg = BasicColor(p - 40)
+		case p == 48:
+			c.Bg, i = extendedColor(params, i)
+		case p == 49:
+			c.Bg = base.Bg
+		case p >= 90 && p <= 97:
+			c.Fg = BasicColor(p - 90 + 8)
+		case p >= 100 && p <= 107:
+			c.Bg = BasicColor(p - 100 + 8)
+		}
+	}
+}
+
+// extendedColor parses the 38/48 colour forms starting at params[i] and
+// returns the colour along with the index of the last consumed parameter
+func extendedColor(params []int, i int) (CellColor, int) {
+	if i+1 >= len(params) {
+		return CellColor{}, i
+	}
+	switch params[i+1] {
+	case 5:
+		if i+2 < len(params) {
+			return IndexedColor(params[i+2]), i + 2
+		}
+	case 2:
+		if i+4 < len(params) {
+			return RGBColor(uint8(params[i+2]), uint8(params[i+3]), uint8(params[i+4])), i + 4
+		}
+	}
+	return CellColor{}, len(params)
+}
+
+// parseParams splits CSI parameters on ';' (and ':' sub-parameters) into
+// integers, using 0 for omitted values
+func parseParams(s string) []int {
+	if s == "" {
+		return nil
+	}
+	params := make([]int, 0, 4)
+	start := 0
+	for i := 0; i <= len(s); i++ {
+		if i == len(s) || s[i] == ';' || s[i] == ':' {
+			n, _ := strconv.Atoi(s[start:i])
+			params = append(params, n)
+			start = i + 1
+		}
+	}
+	return params
+}
+
+// clamp limits v to the range [lo, hi]
+func clamp(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
@@ -0,0 +1,221 @@
+package brew
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestVirtualTerminalScreen(t *testing.T) {
+	tests := []struct {
+		name       string
+		width      int
+		height     int
+		input      string
+		screen     []string
+		scrollback []string
+		cursor     Position
+	}{
+		{
+			name:   "plain text",
+			width:  10,
+			height: 3,
+			input:  "hello\nworld",
+			screen: []string{"hello", "world", ""},
+			cursor: Position{X: 5, Y: 1},
+		},
+		{
+			name:   "autowrap",
+			width:  4,
+			height: 3,
+			input:  "abcdef",
+			screen: []string{"abcd", "ef", ""},
+			cursor: Position{X: 2, Y: 1},
+		},
+		{
+			name:   "pending wrap at the right margin",
+			width:  4,
+			height: 2,
+			input:  "abcd\r",
+			screen: []string{"abcd", ""},
+			cursor: Position{X: 0, Y: 0},
+		},
+		{
+			name:   "autowrap off overwrites the last column",
+			width:  4,
+			height: 2,
+			input:  "\x1b[?7labcdef",
+			screen: []string{"abcf", ""},
+			cursor: Position{X: 3, Y: 0},
+		},
+		{
+			name:   "wide characters wrap as a whole",
+			width:  5,
+			height: 2,
+			input:  "ab日本",
+			screen: []string{"ab日", "本"},
+			cursor: Position{X: 2, Y: 1},
+		},
+		{
+			name:   "wide character on a one column screen",
+			width:  1,
+			height: 2,
+			input:  "\x1b[?7l日x",
+			screen: []string{"x", ""},
+			cursor: Position{X: 0, Y: 0},
+		},
+		{
+			name:   "wide character on a one column screen with autowrap",
+			width:  1,
+			height: 2,
+			input:  "日",
+			screen: []string{"", ""},
+			cursor: Position{X: 0, Y: 0},
+		},
+		{
+			name:   "overwriting the leading half of a wide character",
+			width:  6,
+			height: 1,
+			input:  "日本\x1b[1Gx",
+			screen: []string{"x 本"},
+			cursor: Position{X: 1, Y: 0},
+		},
+		{
+			name:   "overwriting the trailing half of a wide character",
+			width:  6,
+			height: 1,
+			input:  "日本\x1b[2Gx",
+			screen: []string{" x本"},
+			cursor: Position{X: 2, Y: 0},
+		},
+		{
+			name:   "wide character over the halves of two others",
+			width:  6,
+			height: 1,
+			input:  "日本\x1b[2G語",
+			screen: []string{" 語"},
+			cursor: Position{X: 3, Y: 0},
+		},
+		{
+			name:       "scrolling",
+			width:      5,
+			height:     2,
+			input:      "one\ntwo\nthree",
+			screen:     []string{"two", "three"},
+			scrollback: []string{"one"},
+			cursor:     Position{X: 4, Y: 1},
+		},
+		{
+			name:   "cursor movement and erase in line",
+			width:  10,
+			height: 3,
+			input:  "abcdef\x1b[3D\x1b[K\x1b[2;2Hx\x1b[1Ay",
+			screen: []string{"aby", " x", ""},
+			cursor: Position{X: 3, Y: 0},
+		},
+		{
+			name:   "erase below",
+			width:  5,
+			height: 3,
+			input:  "aaa\nbbb\nccc\x1b[2;2H\x1b[J",
+			screen: []string{"aaa", "b", ""},
+			cursor: Position{X: 1, Y: 1},
+		},
+		{
+			name:   "scroll region",
+			width:  5,
+			height: 4,
+			input:  "top\x1b[2;3r\x1b[2;1Ha\nb\nc\x1b[r\x1b[4;1Hend",
+			screen: []string{"top", "b", "c", "end"},
+			cursor: Position{X: 3, Y: 3},
+		},
+		{
+			name:   "insert and delete characters",
+			width:  10,
+			height: 1,
+			input:  "abcdef\x1b[1G\x1b[2P\x1b[2@",
+			screen: []string{"  cdef"},
+			cursor: Position{X: 0, Y: 0},
+		},
+		{
+			name:   "save and restore cursor",
+			width:  10,
+			height: 2,
+			input:  "ab\x1b7\ncd\x1b8ef",
+			screen: []string{"abef", "cd"},
+			cursor: Position{X: 4, Y: 0},
+		},
+		{
+			name:   "string sequences are not printed",
+			width:  10,
+			height: 1,
+			input:  "\x1b]8;;http://example.com\x1b\\link\x1b]8;;\x07!",
+			screen: []string{"link!"},
+			cursor: Position{X: 5, Y: 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vt := NewVirtualTerminal(tt.width, tt.height)
+			vt.Write([]byte(tt.input))
+
+			if got := vt.Screen(); !slices.Equal(got, tt.screen) {
+				t.Errorf("screen = %q, want %q", got, tt.screen)
+			}
+			if got := vt.Scrollback(); !slices.Equal(got, tt.scrollback) {
+				t.Errorf("scrollback = %q, want %q", got, tt.scrollback)
+			}
+			if got := vt.Cursor(); got != tt.cursor {
+				t.Errorf("cursor = %+v, want %+v", got, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestVirtualTerminalSGR(t *testing.T) {
+	vt := NewVirtualTerminal(10, 1)
+	vt.Write([]byte("\x1b[1;31ma\x1b[22;48;5;200mb\x1b[38;2;1;2;3mc\x1b[0md"))
+
+	tests := []struct {
+		x    int
+		want Cell
+	}{
+		{0, Cell{Rune: 'a', Width: 1, Fg: BasicColor(1), Attrs: AttrBold}},
+		{1, Cell{Rune: 'b', Width: 1, Fg: BasicColor(1), Bg: IndexedColor(200)}},
+		{2, Cell{Rune: 'c', Width: 1, Fg: RGBColor(1, 2, 3), Bg: IndexedColor(200)}},
+		{3, Cell{Rune: 'd', Width: 1}},
+	}
+	for _, tt := range tests {
+		if got := vt.Cell(tt.x, 0); got != tt.want {
+			t.Errorf("cell %d = %+v, want %+v", tt.x, got, tt.want)
+		}
+	}
+}
+
+func TestVirtualTerminalCursorVisibility(t *testing.T) {
+	vt := NewVirtualTerminal(10, 1)
+	vt.Write([]byte("\x1b[?25l"))
+	if vt.CursorVisible() {
+		t.Error("cursor visible after DECRST 25")
+	}
+	vt.Write([]byte("\x1b[?25h"))
+	if !vt.CursorVisible() {
+		t.Error("cursor hidden after DECSET 25")
+	}
+}
+
+func TestVirtualTerminalResize(t *testing.T) {
+	vt := NewVirtualTerminal(6, 3)
+	vt.Write([]byte("one\ntwo\nthree"))
+	vt.Resize(4, 2)
+
+	if got, want := vt.Screen(), []string{"two", "thre"}; !slices.Equal(got, want) {
+		t.Errorf("screen = %q, want %q", got, want)
+	}
+	if got, want := vt.Scrollback(), []string{"one"}; !slices.Equal(got, want) {
+		t.Errorf("scrollback = %q, want %q", got, want)
+	}
+	if got, want := vt.Cursor(), (Position{X: 3, Y: 1}); got != want {
+		t.Errorf("cursor = %+v, want %+v", got, want)
+	}
+}